@@ -0,0 +1,103 @@
+package flv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	errInvalidAAC = errors.New("flv: invalid aac audio specific config")
+	errADTS       = errors.New("flv: aac config not representable in adts header")
+)
+
+var aacSampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// AudioSpecificConfig represents AAC AudioSpecificConfig carried by AAC sequence header.
+type AudioSpecificConfig struct {
+	ObjectType     int // audio object type, 2 = AAC LC
+	FrequencyIndex int // sampling frequency index, 15 if the sample rate is explicit
+	SampleRate     int
+	ChannelConfig  int
+}
+
+// ParseAudioSpecificConfig reads AudioSpecificConfig from r.
+func ParseAudioSpecificConfig(r io.Reader) (*AudioSpecificConfig, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	br := &bitReader{b: b}
+	c := &AudioSpecificConfig{}
+	v, err := br.readBits(5)
+	if err != nil {
+		return nil, err
+	}
+	if v == 31 {
+		if v, err = br.readBits(6); err != nil {
+			return nil, err
+		}
+		v += 32
+	}
+	c.ObjectType = int(v)
+	if v, err = br.readBits(4); err != nil {
+		return nil, err
+	}
+	c.FrequencyIndex = int(v)
+	switch {
+	case v == 15:
+		if v, err = br.readBits(24); err != nil {
+			return nil, err
+		}
+		c.SampleRate = int(v)
+	case int(v) < len(aacSampleRates):
+		c.SampleRate = aacSampleRates[v]
+	default:
+		return nil, errInvalidAAC
+	}
+	if v, err = br.readBits(4); err != nil {
+		return nil, err
+	}
+	c.ChannelConfig = int(v)
+	return c, nil
+}
+
+// BuildADTSHeader returns 7-byte ADTS header without CRC for raw AAC frame of frameLen bytes,
+// which precedes the frame in ADTS stream. The frame length field of the header includes the header itself.
+// The sample rate is mapped back to the frequency index if it was explicit in the config.
+// HE-AAC object types are written as AAC LC with the core sample rate of the config, for implicit SBR signaling.
+// It returns an error if the object type, the sample rate or the frame length cannot be represented in ADTS.
+func BuildADTSHeader(cfg *AudioSpecificConfig, frameLen int) ([7]byte, error) {
+	profile := cfg.ObjectType
+	switch {
+	case profile == 5 || profile == 29:
+		profile = 2
+	case profile < 1 || profile > 4:
+		return [7]byte{}, fmt.Errorf("%w: object type %d", errADTS, cfg.ObjectType)
+	}
+	i := cfg.FrequencyIndex
+	if i == 15 {
+		for j, rate := range aacSampleRates {
+			if rate == cfg.SampleRate {
+				i = j
+				break
+			}
+		}
+	}
+	if i < 0 || i >= len(aacSampleRates) {
+		return [7]byte{}, fmt.Errorf("%w: sample rate %d", errADTS, cfg.SampleRate)
+	}
+	n := frameLen + 7
+	if frameLen < 0 || n >= 1<<13 {
+		return [7]byte{}, fmt.Errorf("%w: frame of %d bytes", errADTS, frameLen)
+	}
+	return [7]byte{
+		0xff,
+		0xf1, // MPEG-4, layer 0, no CRC
+		byte(profile-1)<<6 | byte(i)<<2 | byte(cfg.ChannelConfig)>>2&1,
+		byte(cfg.ChannelConfig)&3<<6 | byte(n>>11)&3,
+		byte(n >> 3),
+		byte(n)&7<<5 | 0x1f, // buffer fullness 0x7ff, variable bitrate
+		0xfc,
+	}, nil
+}
@@ -0,0 +1,74 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestParseAudioSpecificConfig(t *testing.T) {
+	for _, it := range []struct {
+		b      []byte
+		config AudioSpecificConfig
+	}{
+		{[]byte{0x12, 0x10}, AudioSpecificConfig{ObjectType: 2, FrequencyIndex: 4, SampleRate: 44100, ChannelConfig: 2}},
+		{[]byte{0x11, 0x90, 0x56, 0xe5, 0x00}, AudioSpecificConfig{ObjectType: 2, FrequencyIndex: 3, SampleRate: 48000, ChannelConfig: 2}},
+		{[]byte{0x17, 0x80, 0x2b, 0x11, 0x08}, AudioSpecificConfig{ObjectType: 2, FrequencyIndex: 15, SampleRate: 22050, ChannelConfig: 1}},
+		{[]byte{0xf8, 0xa6, 0x40}, AudioSpecificConfig{ObjectType: 37, FrequencyIndex: 3, SampleRate: 48000, ChannelConfig: 2}},
+	} {
+		c, err := ParseAudioSpecificConfig(bytes.NewReader(it.b))
+		if err != nil {
+			t.Fatalf("%v: %x", err, it.b)
+		}
+		if *c != it.config {
+			t.Errorf("got: %#v, expected: %#v", c, it.config)
+		}
+	}
+	if _, err := ParseAudioSpecificConfig(bytes.NewReader([]byte{0x12})); err != io.ErrUnexpectedEOF {
+		t.Errorf("got: %v, expected: %v", err, io.ErrUnexpectedEOF)
+	}
+	if _, err := ParseAudioSpecificConfig(bytes.NewReader([]byte{0x16, 0x90})); err != errInvalidAAC {
+		t.Errorf("got: %v, expected: %v", err, errInvalidAAC)
+	}
+}
+
+func TestBuildADTSHeader(t *testing.T) {
+	for _, it := range []struct {
+		config AudioSpecificConfig
+		n      int
+		b      [7]byte
+	}{
+		{AudioSpecificConfig{ObjectType: 2, FrequencyIndex: 4, SampleRate: 44100, ChannelConfig: 2}, 371, [7]byte{0xff, 0xf1, 0x50, 0x80, 0x2f, 0x5f, 0xfc}},
+		{AudioSpecificConfig{ObjectType: 2, FrequencyIndex: 15, SampleRate: 22050, ChannelConfig: 1}, 0x1ff8, [7]byte{0xff, 0xf1, 0x5c, 0x43, 0xff, 0xff, 0xfc}},
+		{AudioSpecificConfig{ObjectType: 1, FrequencyIndex: 3, SampleRate: 48000, ChannelConfig: 6}, 100, [7]byte{0xff, 0xf1, 0x0d, 0x80, 0x0d, 0x7f, 0xfc}},
+		// HE-AAC is written as AAC LC.
+		{AudioSpecificConfig{ObjectType: 5, FrequencyIndex: 6, SampleRate: 24000, ChannelConfig: 2}, 371, [7]byte{0xff, 0xf1, 0x58, 0x80, 0x2f, 0x5f, 0xfc}},
+	} {
+		b, err := BuildADTSHeader(&it.config, it.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b != it.b {
+			t.Errorf("got: %x, expected: %x", b, it.b)
+		}
+		// Frame length including the header.
+		if n := int(b[3]&3)<<11 | int(b[4])<<3 | int(b[5]>>5); n != it.n+7 {
+			t.Errorf("got frame length %d, expected %d", n, it.n+7)
+		}
+	}
+	for _, it := range []struct {
+		config AudioSpecificConfig
+		n      int
+	}{
+		{AudioSpecificConfig{ObjectType: 37, FrequencyIndex: 3, SampleRate: 48000, ChannelConfig: 2}, 100},
+		{AudioSpecificConfig{ObjectType: 0, FrequencyIndex: 3, SampleRate: 48000, ChannelConfig: 2}, 100},
+		// The explicit sample rate has no frequency index.
+		{AudioSpecificConfig{ObjectType: 2, FrequencyIndex: 15, SampleRate: 44000, ChannelConfig: 2}, 100},
+		{AudioSpecificConfig{ObjectType: 2, FrequencyIndex: 4, SampleRate: 44100, ChannelConfig: 2}, 1<<13 - 7},
+	} {
+		if _, err := BuildADTSHeader(&it.config, it.n); !errors.Is(err, errADTS) {
+			t.Errorf("%+v: got: %v, expected: %v", it.config, err, errADTS)
+		}
+	}
+}
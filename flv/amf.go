@@ -0,0 +1,319 @@
+package flv
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+var (
+	errUnsupportedAMF = errors.New("flv: unsupported amf0 type")
+	errAMFKeyTooLong  = errors.New("flv: amf0 property name too long")
+	errAMFDepth       = errors.New("flv: amf0 value nested too deep")
+)
+
+// maxAMFDepth limits nesting of decoded objects and arrays.
+const maxAMFDepth = 64
+
+// AMF0 type markers.
+const (
+	amf0Number      = 0x00
+	amf0Boolean     = 0x01
+	amf0String      = 0x02
+	amf0Object      = 0x03
+	amf0Null        = 0x05
+	amf0Undefined   = 0x06
+	amf0ECMAArray   = 0x08
+	amf0ObjectEnd   = 0x09
+	amf0StrictArray = 0x0a
+	amf0Date        = 0x0b
+	amf0LongString  = 0x0c
+	amf0AVMPlus     = 0x11 // switch to AMF3 for the following value
+)
+
+// DecodeAMF0 reads a single AMF0-encoded value from r.
+// Numbers are decoded into float64, strings into string, booleans into bool,
+// objects and ECMA arrays into map[string]interface{}, strict arrays into []interface{},
+// dates into time.Time, null and undefined into nil.
+// Values switched to AMF3 by the AVM+ marker are decoded into the same types,
+// with integers into float64, XML into string and byte arrays into []byte.
+func DecodeAMF0(r io.Reader) (interface{}, error) {
+	d := &amf0Decoder{r: r}
+	return d.decode()
+}
+
+type amf0Decoder struct {
+	r     io.Reader
+	buf   [8]byte
+	depth int          // nesting level of the value being decoded
+	avm   *amf3Decoder // decoder of AMF3 values, nil until the first switch to AMF3
+}
+
+func (d *amf0Decoder) next(n int) ([]byte, error) {
+	b := d.buf[:n]
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (d *amf0Decoder) decode() (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.decodeValue(b[0])
+	return v, unexpectedEOF(err)
+}
+
+func (d *amf0Decoder) decodeValue(marker byte) (interface{}, error) {
+	switch marker {
+	case amf0Object, amf0ECMAArray, amf0StrictArray:
+		if d.depth++; d.depth > maxAMFDepth {
+			return nil, errAMFDepth
+		}
+		defer func() { d.depth-- }()
+	}
+	switch marker {
+	case amf0Number:
+		return d.readNumber()
+	case amf0Boolean:
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case amf0String:
+		return d.readString()
+	case amf0LongString:
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return d.readUTF8(int(getUint32(b)))
+	case amf0Object:
+		return d.readProperties()
+	case amf0ECMAArray:
+		if _, err := d.next(4); err != nil {
+			return nil, err
+		}
+		return d.readProperties()
+	case amf0StrictArray:
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		n := getUint32(b)
+		v := make([]interface{}, 0)
+		for i := uint32(0); i < n; i++ {
+			it, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			v = append(v, it)
+		}
+		return v, nil
+	case amf0Date:
+		ms, err := d.readNumber()
+		if err != nil {
+			return nil, err
+		}
+		// Time zone is reserved and should be ignored.
+		if _, err = d.next(2); err != nil {
+			return nil, err
+		}
+		return time.UnixMilli(int64(ms)).UTC(), nil
+	case amf0Null, amf0Undefined:
+		return nil, nil
+	case amf0AVMPlus:
+		return d.amf3().decode()
+	}
+	return nil, errUnsupportedAMF
+}
+
+func (d *amf0Decoder) readNumber() (float64, error) {
+	b, err := d.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(uint64(getUint32(b))<<32 | uint64(getUint32(b[4:]))), nil
+}
+
+func (d *amf0Decoder) readString() (string, error) {
+	b, err := d.next(2)
+	if err != nil {
+		return "", err
+	}
+	return d.readUTF8(int(b[0])<<8 | int(b[1]))
+}
+
+func (d *amf0Decoder) readUTF8(n int) (string, error) {
+	if n > 0xffff {
+		// Long string length is not trusted to allocate it at once.
+		b, err := io.ReadAll(io.LimitReader(d.r, int64(n)))
+		if err == nil && len(b) < n {
+			err = io.ErrUnexpectedEOF
+		}
+		return string(b), err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (d *amf0Decoder) readProperties() (map[string]interface{}, error) {
+	v := make(map[string]interface{})
+	for {
+		k, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		if k == "" && b[0] == amf0ObjectEnd {
+			return v, nil
+		}
+		if v[k], err = d.decodeValue(b[0]); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// EncodeAMF0 writes v to w as a single AMF0-encoded value.
+// It is the counterpart of DecodeAMF0: float64 is encoded as number, string as string,
+// or long string if it is longer than 65535 bytes, bool as boolean, map[string]interface{} as ECMA array
+// with keys in sorted order, []interface{} as strict array, time.Time as date and nil as null.
+// Integers are encoded as numbers.
+func EncodeAMF0(w io.Writer, v interface{}) error {
+	e := &amf0Encoder{}
+	if err := e.encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(e.buf)
+	return err
+}
+
+type amf0Encoder struct {
+	buf []byte
+}
+
+type amf0Property struct {
+	key   string
+	value interface{}
+}
+
+func (e *amf0Encoder) encode(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf = append(e.buf, amf0Null)
+	case float64:
+		e.writeNumber(v)
+	case int:
+		e.writeNumber(float64(v))
+	case int64:
+		e.writeNumber(float64(v))
+	case bool:
+		e.writeBoolean(v)
+	case string:
+		e.writeString(v)
+	case time.Time:
+		e.buf = append(e.buf, amf0Date)
+		e.putNumber(float64(v.UnixMilli()))
+		e.buf = append(e.buf, 0, 0)
+	case []interface{}:
+		b := e.next(5)
+		b[0] = amf0StrictArray
+		putUint32(b[1:], uint32(len(v)))
+		for _, it := range v {
+			if err := e.encode(it); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		props := make([]amf0Property, 0, len(v))
+		for k, it := range v {
+			props = append(props, amf0Property{k, it})
+		}
+		sort.Slice(props, func(i, j int) bool { return props[i].key < props[j].key })
+		return e.writeECMAArray(props)
+	case []amf0Property:
+		return e.writeObject(v)
+	default:
+		return errUnsupportedAMF
+	}
+	return nil
+}
+
+func (e *amf0Encoder) next(n int) (v []byte) {
+	v, e.buf = grow(e.buf, n)
+	return
+}
+
+func (e *amf0Encoder) putNumber(v float64) {
+	b := e.next(8)
+	u := math.Float64bits(v)
+	putUint32(b, uint32(u>>32))
+	putUint32(b[4:], uint32(u))
+}
+
+func (e *amf0Encoder) writeNumber(v float64) {
+	e.buf = append(e.buf, amf0Number)
+	e.putNumber(v)
+}
+
+func (e *amf0Encoder) writeBoolean(v bool) {
+	var b byte
+	if v {
+		b = 1
+	}
+	e.buf = append(e.buf, amf0Boolean, b)
+}
+
+func (e *amf0Encoder) writeString(v string) {
+	if len(v) > 0xffff {
+		b := e.next(5)
+		b[0] = amf0LongString
+		putUint32(b[1:], uint32(len(v)))
+		e.buf = append(e.buf, v...)
+		return
+	}
+	e.buf = append(e.buf, amf0String)
+	e.putUTF8(v)
+}
+
+func (e *amf0Encoder) putUTF8(v string) {
+	e.buf = append(e.buf, byte(len(v)>>8), byte(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *amf0Encoder) writeObject(props []amf0Property) error {
+	e.buf = append(e.buf, amf0Object)
+	return e.putProperties(props)
+}
+
+func (e *amf0Encoder) writeECMAArray(props []amf0Property) error {
+	b := e.next(5)
+	b[0] = amf0ECMAArray
+	putUint32(b[1:], uint32(len(props)))
+	return e.putProperties(props)
+}
+
+func (e *amf0Encoder) putProperties(props []amf0Property) error {
+	for _, it := range props {
+		if len(it.key) > 0xffff {
+			return errAMFKeyTooLong
+		}
+		e.putUTF8(it.key)
+		if err := e.encode(it.value); err != nil {
+			return err
+		}
+	}
+	e.buf = append(e.buf, 0, 0, amf0ObjectEnd)
+	return nil
+}
@@ -0,0 +1,272 @@
+package flv
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+var errAMF3Reference = errors.New("flv: invalid amf3 reference")
+
+// AMF3 type markers.
+const (
+	amf3Undefined = 0x00
+	amf3Null      = 0x01
+	amf3False     = 0x02
+	amf3True      = 0x03
+	amf3Integer   = 0x04
+	amf3Double    = 0x05
+	amf3String    = 0x06
+	amf3XMLDoc    = 0x07
+	amf3Date      = 0x08
+	amf3Array     = 0x09
+	amf3Object    = 0x0a
+	amf3XML       = 0x0b
+	amf3ByteArray = 0x0c
+)
+
+// amf3Decoder decodes AMF3 values following the AVM+ marker of AMF0.
+// Its reference tables are shared by all AMF3 values of a single DecodeAMF0 call.
+type amf3Decoder struct {
+	*amf0Decoder
+	strings []string
+	objects []interface{}
+	traits  []*amf3Traits
+}
+
+type amf3Traits struct {
+	dynamic bool
+	members []string
+}
+
+// amf3 returns the AMF3 decoder of d, creating it on the first switch to AMF3.
+func (d *amf0Decoder) amf3() *amf3Decoder {
+	if d.avm == nil {
+		d.avm = &amf3Decoder{amf0Decoder: d}
+	}
+	return d.avm
+}
+
+// decode reads a single AMF3 value. Arrays with associative elements are decoded into map[string]interface{}
+// holding the dense elements by their decimal indexes, class names of objects are dropped.
+func (d *amf3Decoder) decode() (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	switch b[0] {
+	case amf3Undefined, amf3Null:
+		return nil, nil
+	case amf3False:
+		return false, nil
+	case amf3True:
+		return true, nil
+	case amf3Integer:
+		v, err := d.readU29()
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend 29-bit integer.
+		return float64(int32(v<<3) >> 3), nil
+	case amf3Double:
+		return d.readNumber()
+	case amf3String:
+		return d.readString()
+	case amf3XMLDoc, amf3XML:
+		v, ok, err := d.readReference()
+		if ok || err != nil {
+			return v, err
+		}
+		s, err := d.readUTF8(int(v.(uint32)))
+		if err != nil {
+			return nil, err
+		}
+		d.objects = append(d.objects, s)
+		return s, nil
+	case amf3Date:
+		v, ok, err := d.readReference()
+		if ok || err != nil {
+			return v, err
+		}
+		ms, err := d.readNumber()
+		if err != nil {
+			return nil, err
+		}
+		t := time.UnixMilli(int64(ms)).UTC()
+		d.objects = append(d.objects, t)
+		return t, nil
+	case amf3ByteArray:
+		v, ok, err := d.readReference()
+		if ok || err != nil {
+			return v, err
+		}
+		s, err := d.readUTF8(int(v.(uint32)))
+		if err != nil {
+			return nil, err
+		}
+		d.objects = append(d.objects, []byte(s))
+		return []byte(s), nil
+	case amf3Array, amf3Object:
+		if d.depth++; d.depth > maxAMFDepth {
+			return nil, errAMFDepth
+		}
+		defer func() { d.depth-- }()
+		if b[0] == amf3Array {
+			return d.readArray()
+		}
+		return d.readObject()
+	}
+	return nil, errUnsupportedAMF
+}
+
+// readU29 reads variable length 29-bit unsigned integer.
+func (d *amf3Decoder) readU29() (uint32, error) {
+	var v uint32
+	for i := 0; i < 4; i++ {
+		b, err := d.next(1)
+		if err != nil {
+			return 0, err
+		}
+		if i == 3 {
+			return v<<8 | uint32(b[0]), nil
+		}
+		v = v<<7 | uint32(b[0]&0x7f)
+		if b[0]&0x80 == 0 {
+			break
+		}
+	}
+	return v, nil
+}
+
+// readReference reads U29 header of a complex value. If its low bit is clear, it returns the value
+// referenced in the object table with ok set, otherwise the remaining bits of the header as uint32.
+func (d *amf3Decoder) readReference() (v interface{}, ok bool, err error) {
+	u, err := d.readU29()
+	if err != nil {
+		return nil, false, err
+	}
+	if u&1 != 0 {
+		return u >> 1, false, nil
+	}
+	if int(u>>1) >= len(d.objects) {
+		return nil, false, errAMF3Reference
+	}
+	return d.objects[u>>1], true, nil
+}
+
+func (d *amf3Decoder) readString() (string, error) {
+	u, err := d.readU29()
+	if err != nil {
+		return "", err
+	}
+	if u&1 == 0 {
+		if int(u>>1) >= len(d.strings) {
+			return "", errAMF3Reference
+		}
+		return d.strings[u>>1], nil
+	}
+	s, err := d.readUTF8(int(u >> 1))
+	if err != nil {
+		return "", err
+	}
+	// Empty string is never sent by reference.
+	if s != "" {
+		d.strings = append(d.strings, s)
+	}
+	return s, nil
+}
+
+func (d *amf3Decoder) readArray() (interface{}, error) {
+	v, ok, err := d.readReference()
+	if ok || err != nil {
+		return v, err
+	}
+	n := v.(uint32)
+	i := len(d.objects)
+	d.objects = append(d.objects, nil)
+	var m map[string]interface{}
+	for {
+		k, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		if k == "" {
+			break
+		}
+		if m == nil {
+			m = make(map[string]interface{})
+		}
+		if m[k], err = d.decode(); err != nil {
+			return nil, err
+		}
+	}
+	a := make([]interface{}, 0)
+	for j := uint32(0); j < n; j++ {
+		it, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, it)
+	}
+	if m == nil {
+		d.objects[i] = a
+		return a, nil
+	}
+	for j, it := range a {
+		m[strconv.Itoa(j)] = it
+	}
+	d.objects[i] = m
+	return m, nil
+}
+
+func (d *amf3Decoder) readObject() (interface{}, error) {
+	v, ok, err := d.readReference()
+	if ok || err != nil {
+		return v, err
+	}
+	u := v.(uint32)
+	var t *amf3Traits
+	switch {
+	case u&1 == 0:
+		if int(u>>1) >= len(d.traits) {
+			return nil, errAMF3Reference
+		}
+		t = d.traits[u>>1]
+	case u&2 != 0:
+		// Externalizable objects are encoded by their classes.
+		return nil, errUnsupportedAMF
+	default:
+		t = &amf3Traits{dynamic: u&4 != 0}
+		if _, err = d.readString(); err != nil {
+			return nil, err
+		}
+		for j := uint32(0); j < u>>3; j++ {
+			k, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			t.members = append(t.members, k)
+		}
+		d.traits = append(d.traits, t)
+	}
+	m := make(map[string]interface{})
+	d.objects = append(d.objects, m)
+	for _, k := range t.members {
+		if m[k], err = d.decode(); err != nil {
+			return nil, err
+		}
+	}
+	for t.dynamic {
+		k, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		if k == "" {
+			break
+		}
+		if m[k], err = d.decode(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
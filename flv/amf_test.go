@@ -0,0 +1,214 @@
+package flv
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// onMetaData script tag payload as written by ffmpeg.
+var metaDataPayload = []byte{
+	0x02, 0x00, 0x0a, 0x6f, 0x6e, 0x4d, 0x65, 0x74, 0x61, 0x44, 0x61, 0x74, 0x61, 0x08, 0x00, 0x00,
+	0x00, 0x0d, 0x00, 0x08, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x00, 0x40, 0x24, 0x05,
+	0x1e, 0xb8, 0x51, 0xeb, 0x85, 0x00, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x00, 0x40, 0x94, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x00, 0x40, 0x86,
+	0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0d, 0x76, 0x69, 0x64, 0x65, 0x6f, 0x64, 0x61, 0x74,
+	0x61, 0x72, 0x61, 0x74, 0x65, 0x00, 0x40, 0x9e, 0x84, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x09,
+	0x66, 0x72, 0x61, 0x6d, 0x65, 0x72, 0x61, 0x74, 0x65, 0x00, 0x40, 0x3e, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x0c, 0x76, 0x69, 0x64, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x63, 0x69, 0x64,
+	0x00, 0x40, 0x1c, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0d, 0x61, 0x75, 0x64, 0x69, 0x6f,
+	0x64, 0x61, 0x74, 0x61, 0x72, 0x61, 0x74, 0x65, 0x00, 0x40, 0x5f, 0x40, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x0f, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x72, 0x61,
+	0x74, 0x65, 0x00, 0x40, 0xe5, 0x88, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0f, 0x61, 0x75, 0x64,
+	0x69, 0x6f, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x00, 0x40, 0x30, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06, 0x73, 0x74, 0x65, 0x72, 0x65, 0x6f, 0x01, 0x01, 0x00,
+	0x0c, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x63, 0x69, 0x64, 0x00, 0x40, 0x24,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07, 0x65, 0x6e, 0x63, 0x6f, 0x64, 0x65, 0x72, 0x02,
+	0x00, 0x0d, 0x4c, 0x61, 0x76, 0x66, 0x35, 0x38, 0x2e, 0x37, 0x36, 0x2e, 0x31, 0x30, 0x30, 0x00,
+	0x08, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x00, 0x41, 0x44, 0x63, 0x6d, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x09,
+}
+
+func TestDecodeAMF0MetaData(t *testing.T) {
+	r := bytes.NewReader(metaDataPayload)
+	name, err := DecodeAMF0(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "onMetaData" {
+		t.Fatalf("got: %#v, expected: onMetaData", name)
+	}
+	v, err := DecodeAMF0(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"duration":        10.01,
+		"width":           1280.0,
+		"height":          720.0,
+		"videodatarate":   1953.125,
+		"framerate":       30.0,
+		"videocodecid":    7.0,
+		"audiodatarate":   125.0,
+		"audiosamplerate": 44100.0,
+		"audiosamplesize": 16.0,
+		"stereo":          true,
+		"audiocodecid":    10.0,
+		"encoder":         "Lavf58.76.100",
+		"filesize":        2672346.0,
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("got: %#v, expected: %#v", v, expected)
+	}
+	if r.Len() != 0 {
+		t.Errorf("%d bytes left", r.Len())
+	}
+}
+
+func TestDecodeAMF0(t *testing.T) {
+	for _, it := range []struct {
+		b []byte
+		v interface{}
+	}{
+		{[]byte{0x01, 0x00}, false},
+		{[]byte{0x05}, nil},
+		{[]byte{0x06}, nil},
+		{[]byte{0x0c, 0x00, 0x00, 0x00, 0x02, 'o', 'k'}, "ok"},
+		{[]byte{0x0a, 0x00, 0x00, 0x00, 0x02, 0x02, 0x00, 0x01, 'a', 0x05}, []interface{}{"a", nil}},
+		{[]byte{0x03, 0x00, 0x01, 'a', 0x03, 0x00, 0x00, 0x09, 0x00, 0x00, 0x09}, map[string]interface{}{"a": map[string]interface{}{}}},
+		{[]byte{0x0b, 0x42, 0x75, 0x39, 0x8a, 0x06, 0x9b, 0x80, 0x00, 0x00, 0x00}, time.Date(2016, 3, 21, 10, 2, 43, 0, time.UTC)},
+	} {
+		v, err := DecodeAMF0(bytes.NewReader(it.b))
+		if err != nil {
+			t.Fatalf("%v: %x", err, it.b)
+		}
+		if !reflect.DeepEqual(v, it.v) {
+			t.Errorf("got: %#v, expected: %#v", v, it.v)
+		}
+	}
+}
+
+func TestEncodeAMF0(t *testing.T) {
+	long := strings.Repeat("x", 0x10000)
+	v := map[string]interface{}{
+		"number": 1.5,
+		"flag":   true,
+		"name":   "test",
+		"long":   long,
+		"date":   time.Date(2016, 3, 21, 10, 2, 43, 0, time.UTC),
+		"list":   []interface{}{"a", 2.0, nil, map[string]interface{}{"nested": []interface{}{}}},
+		"object": map[string]interface{}{"a": map[string]interface{}{}},
+	}
+	b := &bytes.Buffer{}
+	if err := EncodeAMF0(b, v); err != nil {
+		t.Fatal(err)
+	}
+	if i := bytes.Index(b.Bytes(), []byte("long")); i < 0 || b.Bytes()[i+4] != 0x0c {
+		t.Errorf("long string is not encoded with long string marker")
+	}
+	got, err := DecodeAMF0(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Errorf("got: %#v, expected: %#v", got, v)
+	}
+	if b.Len() != 0 {
+		t.Errorf("%d bytes left", b.Len())
+	}
+	b.Reset()
+	if err = EncodeAMF0(b, 7); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte{0x00, 0x40, 0x1c, 0, 0, 0, 0, 0, 0}; !bytes.Equal(b.Bytes(), expected) {
+		t.Errorf("got: %x, expected: %x", b.Bytes(), expected)
+	}
+	if err = EncodeAMF0(b, map[string]interface{}{long: 1.0}); err == nil {
+		t.Error("expected error for too long property name")
+	}
+	if err = EncodeAMF0(b, struct{}{}); err != errUnsupportedAMF {
+		t.Errorf("got: %v, expected: %v", err, errUnsupportedAMF)
+	}
+}
+
+func TestDecodeAMF0Malformed(t *testing.T) {
+	deep := bytes.Repeat([]byte{0x0a, 0, 0, 0, 1}, maxAMFDepth+1)
+	for _, it := range []struct {
+		b   []byte
+		err error
+	}{
+		{deep, errAMFDepth},
+		{[]byte{0x0c, 0xff, 0xff, 0xff, 0xff, 'a'}, io.ErrUnexpectedEOF},
+		{[]byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0x05}, io.ErrUnexpectedEOF},
+		{[]byte{0x03, 0x00, 0x01, 'a'}, io.ErrUnexpectedEOF},
+	} {
+		if _, err := DecodeAMF0(bytes.NewReader(it.b)); err != it.err {
+			t.Errorf("%x: got: %v, expected: %v", it.b[:6], err, it.err)
+		}
+	}
+	if _, err := DecodeAMF0(bytes.NewReader(deep[5:])); err != io.ErrUnexpectedEOF {
+		t.Errorf("got: %v, expected: %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestDecodeAMF3(t *testing.T) {
+	for _, it := range []struct {
+		b []byte
+		v interface{}
+	}{
+		{[]byte{0x11, 0x01}, nil},
+		{[]byte{0x11, 0x03}, true},
+		{[]byte{0x11, 0x04, 0x8a, 0x00}, 1280.0},
+		{[]byte{0x11, 0x04, 0xff, 0xff, 0xff, 0xff}, -1.0},
+		{[]byte{0x11, 0x05, 0x40, 0x24, 0, 0, 0, 0, 0, 0}, 10.0},
+		{[]byte{0x11, 0x06, 0x07, 'a', 'b', 'c'}, "abc"},
+		{[]byte{0x11, 0x08, 0x01, 0x42, 0x75, 0x39, 0x8a, 0x06, 0x9b, 0x80, 0x00}, time.Date(2016, 3, 21, 10, 2, 43, 0, time.UTC)},
+		{[]byte{0x11, 0x0c, 0x07, 1, 2, 3}, []byte{1, 2, 3}},
+		{[]byte{0x11, 0x09, 0x05, 0x01, 0x04, 0x01, 0x03}, []interface{}{1.0, true}},
+		{[]byte{0x11, 0x09, 0x03, 0x03, 'k', 0x02, 0x01, 0x04, 0x07}, map[string]interface{}{"k": false, "0": 7.0}},
+		// Sealed object of class "C" with members a and b, the second one using the traits and the strings by reference.
+		{[]byte{
+			0x03,
+			0x00, 0x01, 'x', 0x11, 0x0a, 0x23, 0x03, 'C', 0x03, 'a', 0x03, 'b', 0x04, 0x01, 0x06, 0x02,
+			0x00, 0x01, 'y', 0x11, 0x0a, 0x01, 0x04, 0x02, 0x06, 0x00,
+			0x00, 0x00, 0x09,
+		}, map[string]interface{}{
+			"x": map[string]interface{}{"a": 1.0, "b": "a"},
+			"y": map[string]interface{}{"a": 2.0, "b": "C"},
+		}},
+	} {
+		v, err := DecodeAMF0(bytes.NewReader(it.b))
+		if err != nil {
+			t.Fatalf("%x: %v", it.b, err)
+		}
+		if !reflect.DeepEqual(v, it.v) {
+			t.Errorf("%x: got: %#v, expected: %#v", it.b, v, it.v)
+		}
+	}
+	for _, b := range [][]byte{
+		{0x11, 0x06, 0x02},
+		{0x11, 0x09, 0x02},
+		{0x11, 0x0a, 0x01},
+	} {
+		if _, err := DecodeAMF0(bytes.NewReader(b)); err != errAMF3Reference {
+			t.Errorf("%x: got: %v, expected: %v", b, err, errAMF3Reference)
+		}
+	}
+
+	// onMetaData switching to AMF3 for a dynamic anonymous object.
+	b := []byte{0x02, 0x00, 0x0a, 'o', 'n', 'M', 'e', 't', 'a', 'D', 'a', 't', 'a', 0x11, 0x0a, 0x0b, 0x01}
+	b = append(b, 0x0b, 'w', 'i', 'd', 't', 'h', 0x04, 0x8a, 0x00)
+	b = append(b, 0x0d, 'h', 'e', 'i', 'g', 'h', 't', 0x04, 0x85, 0x50)
+	b = append(b, 0x0f, 'e', 'n', 'c', 'o', 'd', 'e', 'r', 0x06, 0x03, 'x')
+	b = append(b, 0x0b, 't', 'i', 't', 'l', 'e', 0x06, 0x06, 0x01)
+	m, err := ParseMetadata(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 1280 || m.Height != 720 || m.Encoder != "x" || m.Extra["title"] != "x" {
+		t.Errorf("got: %+v", m)
+	}
+}
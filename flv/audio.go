@@ -2,6 +2,7 @@ package flv
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"time"
 )
@@ -76,6 +77,203 @@ func ParseAudioFormat(b []byte) (*AudioFormat, error) {
 	return c, nil
 }
 
+// SoundFormat is the codec of the audio tag.
+type SoundFormat byte
+
+// Sound formats of the audio tag header.
+const (
+	SoundFormatLinearPCM       SoundFormat = 0  // Linear PCM, platform endian
+	SoundFormatADPCM           SoundFormat = 1  // ADPCM
+	SoundFormatMP3             SoundFormat = 2  // MP3
+	SoundFormatLinearPCMLE     SoundFormat = 3  // Linear PCM, little endian
+	SoundFormatNellymoser16kHz SoundFormat = 4  // Nellymoser 16 kHz mono
+	SoundFormatNellymoser8kHz  SoundFormat = 5  // Nellymoser 8 kHz mono
+	SoundFormatNellymoser      SoundFormat = 6  // Nellymoser
+	SoundFormatG711ALaw        SoundFormat = 7  // G.711 A-law logarithmic PCM
+	SoundFormatG711MuLaw       SoundFormat = 8  // G.711 mu-law logarithmic PCM
+	SoundFormatExHeader        SoundFormat = 9  // enhanced audio header with FourCC
+	SoundFormatAAC             SoundFormat = 10 // AAC
+	SoundFormatSpeex           SoundFormat = 11 // Speex
+	SoundFormatMP38kHz         SoundFormat = 14 // MP3 8 kHz
+	SoundFormatDeviceSpecific  SoundFormat = 15 // Device-specific sound
+)
+
+var soundFormatNames = map[SoundFormat]string{
+	SoundFormatLinearPCM:       "Linear PCM",
+	SoundFormatADPCM:           "ADPCM",
+	SoundFormatMP3:             "MP3",
+	SoundFormatLinearPCMLE:     "Linear PCM LE",
+	SoundFormatNellymoser16kHz: "Nellymoser 16kHz",
+	SoundFormatNellymoser8kHz:  "Nellymoser 8kHz",
+	SoundFormatNellymoser:      "Nellymoser",
+	SoundFormatG711ALaw:        "G.711 A-law",
+	SoundFormatG711MuLaw:       "G.711 mu-law",
+	SoundFormatExHeader:        "ExHeader",
+	SoundFormatAAC:             "AAC",
+	SoundFormatSpeex:           "Speex",
+	SoundFormatMP38kHz:         "MP3 8kHz",
+	SoundFormatDeviceSpecific:  "Device-specific",
+}
+
+// String returns the sound format name.
+func (f SoundFormat) String() string {
+	if s, ok := soundFormatNames[f]; ok {
+		return s
+	}
+	return fmt.Sprintf("SoundFormat(%d)", byte(f))
+}
+
+// AAC packet types of the audio tag header.
+const (
+	AACPacketTypeSequenceHeader byte = 0 // AudioSpecificConfig
+	AACPacketTypeRaw            byte = 1 // raw AAC frame data
+)
+
+// Packet types of the enhanced audio tag header.
+const (
+	AudioPacketTypeSequenceStart      byte = 0
+	AudioPacketTypeCodedFrames        byte = 1
+	AudioPacketTypeSequenceEnd        byte = 2
+	AudioPacketTypeMultichannelConfig byte = 4
+	AudioPacketTypeMultitrack         byte = 5 // frames of several tracks
+)
+
+// FourCC codec identifiers of the enhanced audio tag header.
+var (
+	FourCCOpus = [4]byte{'O', 'p', 'u', 's'}
+	FourCCFLAC = [4]byte{'f', 'L', 'a', 'C'}
+	FourCCAC3  = [4]byte{'a', 'c', '-', '3'}
+	FourCCEAC3 = [4]byte{'e', 'c', '-', '3'}
+	FourCCMP3  = [4]byte{'.', 'm', 'p', '3'}
+	FourCCAAC  = [4]byte{'m', 'p', '4', 'a'}
+)
+
+// AudioHeader represents the header of the audio tag payload.
+// SampleRate, SampleSize and Channels hold the raw bit field values.
+// Enhanced headers as defined by Enhanced RTMP specification have SoundFormatExHeader format
+// and carry FourCC and PacketType instead of the bit fields.
+type AudioHeader struct {
+	Format         SoundFormat
+	SampleRate     byte // 0 = 5.5 kHz, 1 = 11 kHz, 2 = 22 kHz, 3 = 44 kHz
+	SampleSize     byte // 0 = 8-bit samples, 1 = 16-bit samples
+	Channels       byte // 0 = mono, 1 = stereo
+	AACPacketType  byte // AACPacketTypeSequenceHeader or AACPacketTypeRaw, only for AAC format
+	Enhanced       bool
+	FourCC         [4]byte // only for enhanced header
+	PacketType     byte    // only for enhanced header
+	Multitrack     bool    // only for enhanced header, PacketType is the packet type of the tracks then
+	MultitrackType byte    // only for multitrack packet
+	TrackID        byte    // track identifier of multitrack packet, 0 otherwise
+}
+
+// ParseAudioHeader reads the audio tag header from r.
+// It returns the reader positioned at the raw audio data.
+// For multitrack packet the header and data of the first track are returned, see WalkAudioTracks.
+func ParseAudioHeader(r io.Reader) (*AudioHeader, io.Reader, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(r, b[:1]); err != nil {
+		return nil, nil, err
+	}
+	if SoundFormat(b[0]>>4) == SoundFormatExHeader {
+		return parseEnhancedAudioHeader(r, b[0])
+	}
+	h := &AudioHeader{
+		Format:     SoundFormat(b[0] >> 4),
+		SampleRate: b[0] >> 2 & 3,
+		SampleSize: b[0] >> 1 & 1,
+		Channels:   b[0] & 1,
+	}
+	if h.Format == SoundFormatAAC {
+		if _, err := io.ReadFull(r, b[1:]); err != nil {
+			return nil, nil, unexpectedEOF(err)
+		}
+		h.AACPacketType = b[1]
+	}
+	return h, r, nil
+}
+
+func parseEnhancedAudioHeader(r io.Reader, t byte) (*AudioHeader, io.Reader, error) {
+	var b [1]byte
+	h := &AudioHeader{
+		Format:     SoundFormatExHeader,
+		Enhanced:   true,
+		PacketType: t & 0xf,
+	}
+	if h.PacketType == AudioPacketTypeMultitrack {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, nil, unexpectedEOF(err)
+		}
+		h.Multitrack = true
+		h.MultitrackType = b[0] >> 4
+		h.PacketType = b[0] & 0xf
+		if h.MultitrackType != MultitrackTypeManyTracksManyCodecs {
+			if _, err := io.ReadFull(r, h.FourCC[:]); err != nil {
+				return nil, nil, unexpectedEOF(err)
+			}
+		}
+		h, data, err := parseAudioTrack(r, h)
+		return h, data, unexpectedEOF(err)
+	}
+	if _, err := io.ReadFull(r, h.FourCC[:]); err != nil {
+		return nil, nil, unexpectedEOF(err)
+	}
+	return h, r, nil
+}
+
+// parseAudioTrack reads the track header of multitrack packet, it returns io.EOF if there are no more tracks.
+// The returned reader is limited to the track data unless the packet has one track.
+func parseAudioTrack(r io.Reader, h *AudioHeader) (*AudioHeader, io.Reader, error) {
+	var b [3]byte
+	if h.MultitrackType == MultitrackTypeManyTracksManyCodecs {
+		if _, err := io.ReadFull(r, h.FourCC[:]); err != nil {
+			return nil, nil, err
+		}
+		if _, err := io.ReadFull(r, b[:1]); err != nil {
+			return nil, nil, unexpectedEOF(err)
+		}
+	} else if _, err := io.ReadFull(r, b[:1]); err != nil {
+		return nil, nil, err
+	}
+	h.TrackID = b[0]
+	if h.MultitrackType != MultitrackTypeOneTrack {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, nil, unexpectedEOF(err)
+		}
+		r = io.LimitReader(r, int64(getUint24(b[:])))
+	}
+	return h, r, nil
+}
+
+// WalkAudioTracks reads the audio tag payload from r and calls fn for each track of multitrack packet,
+// or once for other packets. The rest of track data not consumed by fn is skipped.
+// WalkAudioTracks stops when fn returns an error.
+func WalkAudioTracks(r io.Reader, fn func(h *AudioHeader, data io.Reader) error) error {
+	h, data, err := ParseAudioHeader(r)
+	if err != nil {
+		return err
+	}
+	for {
+		if err = fn(h, data); err != nil {
+			return err
+		}
+		if !h.Multitrack || h.MultitrackType == MultitrackTypeOneTrack {
+			return nil
+		}
+		if _, err = io.Copy(io.Discard, data); err != nil {
+			return err
+		}
+		next := *h
+		next.TrackID = 0
+		h, data, err = parseAudioTrack(r, &next)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
 type AudioFrame struct {
 	format  *AudioFrame
 	time    time.Duration
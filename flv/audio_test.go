@@ -1,6 +1,10 @@
 package flv
 
-import "testing"
+import (
+	"bytes"
+	"io"
+	"testing"
+)
 
 func TestAudioFormat(t *testing.T) {
 	for _, it := range []struct {
@@ -29,3 +33,143 @@ func TestAudioFormat(t *testing.T) {
 		}
 	}
 }
+
+func TestAudioHeader(t *testing.T) {
+	for _, it := range []struct {
+		b      []byte
+		header AudioHeader
+		data   []byte
+	}{
+		{[]byte{0xaf, 0x00, 0x12, 0x10}, AudioHeader{Format: SoundFormatAAC, SampleRate: 3, SampleSize: 1, Channels: 1, AACPacketType: 0}, []byte{0x12, 0x10}},
+		{[]byte{0xaf, 0x01, 0x21}, AudioHeader{Format: SoundFormatAAC, SampleRate: 3, SampleSize: 1, Channels: 1, AACPacketType: 1}, []byte{0x21}},
+		{[]byte{0x2e, 0xff, 0xfb}, AudioHeader{Format: SoundFormatMP3, SampleRate: 3, SampleSize: 1, Channels: 0}, []byte{0xff, 0xfb}},
+		{[]byte{0xb2, 0x01}, AudioHeader{Format: SoundFormatSpeex, SampleRate: 0, SampleSize: 1, Channels: 0}, []byte{0x01}},
+	} {
+		h, r, err := ParseAudioHeader(bytes.NewReader(it.b))
+		if err != nil {
+			t.Fatalf("%v: %x", err, it.b)
+		}
+		if *h != it.header {
+			t.Errorf("got: %#v, expected: %#v", h, it.header)
+		}
+		data, _ := io.ReadAll(r)
+		if !bytes.Equal(data, it.data) {
+			t.Errorf("got data: %x, expected: %x", data, it.data)
+		}
+	}
+	if _, _, err := ParseAudioHeader(bytes.NewReader([]byte{0xaf})); err != io.ErrUnexpectedEOF {
+		t.Errorf("got: %v, expected: %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestSoundFormatString(t *testing.T) {
+	for f, s := range map[SoundFormat]string{
+		SoundFormatLinearPCM:       "Linear PCM",
+		SoundFormatADPCM:           "ADPCM",
+		SoundFormatMP3:             "MP3",
+		SoundFormatLinearPCMLE:     "Linear PCM LE",
+		SoundFormatNellymoser16kHz: "Nellymoser 16kHz",
+		SoundFormatNellymoser8kHz:  "Nellymoser 8kHz",
+		SoundFormatNellymoser:      "Nellymoser",
+		SoundFormatG711ALaw:        "G.711 A-law",
+		SoundFormatG711MuLaw:       "G.711 mu-law",
+		SoundFormatAAC:             "AAC",
+		SoundFormatSpeex:           "Speex",
+		SoundFormatMP38kHz:         "MP3 8kHz",
+		SoundFormatDeviceSpecific:  "Device-specific",
+		SoundFormatExHeader:        "ExHeader",
+		12:                         "SoundFormat(12)",
+	} {
+		if f.String() != s {
+			t.Errorf("got: %q, expected: %q", f.String(), s)
+		}
+	}
+}
+
+func TestAudioMultitrack(t *testing.T) {
+	opus := [4]byte{'O', 'p', 'u', 's'}
+	type track struct {
+		header AudioHeader
+		data   []byte
+	}
+	for _, it := range []struct {
+		b      []byte
+		tracks []track
+	}{
+		{[]byte{0x95, 0x01, 'O', 'p', 'u', 's', 0x03, 0xfc, 0xff}, []track{
+			{AudioHeader{Format: SoundFormatExHeader, Enhanced: true, FourCC: opus, PacketType: AudioPacketTypeCodedFrames, Multitrack: true, TrackID: 3}, []byte{0xfc, 0xff}},
+		}},
+		{[]byte{0x95, 0x11, 'O', 'p', 'u', 's', 0x00, 0x00, 0x00, 0x02, 0xfc, 0x00, 0x01, 0x00, 0x00, 0x02, 0xf8, 0xff}, []track{
+			{AudioHeader{Format: SoundFormatExHeader, Enhanced: true, FourCC: opus, PacketType: AudioPacketTypeCodedFrames, Multitrack: true, MultitrackType: MultitrackTypeManyTracks}, []byte{0xfc}},
+			{AudioHeader{Format: SoundFormatExHeader, Enhanced: true, FourCC: opus, PacketType: AudioPacketTypeCodedFrames, Multitrack: true, MultitrackType: MultitrackTypeManyTracks, TrackID: 1}, []byte{0xf8, 0xff}},
+		}},
+		{[]byte{0x95, 0x20, 'O', 'p', 'u', 's', 0x00, 0x00, 0x00, 0x01, 0x4f, 'm', 'p', '4', 'a', 0x01, 0x00, 0x00, 0x02, 0x12, 0x10}, []track{
+			{AudioHeader{Format: SoundFormatExHeader, Enhanced: true, FourCC: opus, PacketType: AudioPacketTypeSequenceStart, Multitrack: true, MultitrackType: MultitrackTypeManyTracksManyCodecs}, []byte{0x4f}},
+			{AudioHeader{Format: SoundFormatExHeader, Enhanced: true, FourCC: [4]byte{'m', 'p', '4', 'a'}, PacketType: AudioPacketTypeSequenceStart, Multitrack: true, MultitrackType: MultitrackTypeManyTracksManyCodecs, TrackID: 1}, []byte{0x12, 0x10}},
+		}},
+		{[]byte{0xaf, 0x01, 0x21}, []track{
+			{AudioHeader{Format: SoundFormatAAC, SampleRate: 3, SampleSize: 1, Channels: 1, AACPacketType: AACPacketTypeRaw}, []byte{0x21}},
+		}},
+	} {
+		var got []track
+		err := WalkAudioTracks(bytes.NewReader(it.b), func(h *AudioHeader, data io.Reader) error {
+			// Data of the first track is left unread after the first byte to be skipped.
+			b := make([]byte, 1)
+			if _, err := io.ReadFull(data, b); err != nil {
+				return err
+			}
+			if h.TrackID != 0 || !h.Multitrack || h.MultitrackType == MultitrackTypeOneTrack {
+				rest, _ := io.ReadAll(data)
+				b = append(b, rest...)
+			}
+			got = append(got, track{*h, b})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%v: %x", err, it.b)
+		}
+		if len(got) != len(it.tracks) {
+			t.Fatalf("%x: got %d tracks, expected %d", it.b, len(got), len(it.tracks))
+		}
+		for i, tr := range it.tracks {
+			if got[i].header != tr.header {
+				t.Errorf("track %d: got: %#v, expected: %#v", i, got[i].header, tr.header)
+			}
+			if !bytes.Equal(got[i].data, tr.data) {
+				t.Errorf("track %d: got data: %x, expected: %x", i, got[i].data, tr.data)
+			}
+		}
+	}
+}
+
+func TestEnhancedAudioHeader(t *testing.T) {
+	for _, it := range []struct {
+		b      []byte
+		header AudioHeader
+		data   []byte
+	}{
+		{[]byte{0x90, 'O', 'p', 'u', 's', 'O', 'p', 'u', 's', 'H', 'e', 'a', 'd'}, AudioHeader{Format: SoundFormatExHeader, Enhanced: true, FourCC: FourCCOpus, PacketType: AudioPacketTypeSequenceStart}, []byte("OpusHead")},
+		{[]byte{0x91, 'O', 'p', 'u', 's', 0xfc, 0xff, 0xfe}, AudioHeader{Format: SoundFormatExHeader, Enhanced: true, FourCC: FourCCOpus, PacketType: AudioPacketTypeCodedFrames}, []byte{0xfc, 0xff, 0xfe}},
+		{[]byte{0x90, 'f', 'L', 'a', 'C', 0x00, 0x00, 0x00, 0x22}, AudioHeader{Format: SoundFormatExHeader, Enhanced: true, FourCC: FourCCFLAC, PacketType: AudioPacketTypeSequenceStart}, []byte{0x00, 0x00, 0x00, 0x22}},
+		{[]byte{0x91, 'f', 'L', 'a', 'C', 0xff, 0xf8}, AudioHeader{Format: SoundFormatExHeader, Enhanced: true, FourCC: FourCCFLAC, PacketType: AudioPacketTypeCodedFrames}, []byte{0xff, 0xf8}},
+		{[]byte{0x92, 'O', 'p', 'u', 's'}, AudioHeader{Format: SoundFormatExHeader, Enhanced: true, FourCC: FourCCOpus, PacketType: AudioPacketTypeSequenceEnd}, nil},
+	} {
+		h, r, err := ParseAudioHeader(bytes.NewReader(it.b))
+		if err != nil {
+			t.Fatalf("%v: %x", err, it.b)
+		}
+		if *h != it.header {
+			t.Errorf("got: %#v, expected: %#v", h, it.header)
+		}
+		data, _ := io.ReadAll(r)
+		if !bytes.Equal(data, it.data) {
+			t.Errorf("got data: %x, expected: %x", data, it.data)
+		}
+		if k := sequenceHeader(&Tag{Type: TagTypeAudio}, it.b); (k == 1) != (h.PacketType == AudioPacketTypeSequenceStart) {
+			t.Errorf("%x: got sequence header %d", it.b, k)
+		}
+	}
+	if _, _, err := ParseAudioHeader(bytes.NewReader([]byte{0x91, 'O', 'p'})); err != io.ErrUnexpectedEOF {
+		t.Errorf("got: %v, expected: %v", err, io.ErrUnexpectedEOF)
+	}
+}
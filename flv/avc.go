@@ -0,0 +1,301 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+var (
+	errUnsupportedAVC = errors.New("flv: unsupported avc configuration version")
+	errNALULengthSize = errors.New("flv: invalid nalu length size")
+	errInvalidSPS     = errors.New("flv: invalid sps")
+)
+
+var startCode = []byte{0, 0, 0, 1}
+
+// AVCDecoderConfig represents AVCDecoderConfigurationRecord carried by AVC sequence header.
+type AVCDecoderConfig struct {
+	Version              byte
+	Profile              byte
+	ProfileCompatibility byte
+	Level                byte
+	NALULengthSize       int      // size of NALU length prefix in bytes
+	SPS                  [][]byte // sequence parameter sets
+	PPS                  [][]byte // picture parameter sets
+}
+
+// VideoTrack holds the decoding context of AVC video, which the reader retains from the last sequence header read.
+type VideoTrack struct {
+	Config *AVCDecoderConfig // nil if there is no valid sequence header
+}
+
+// NALULengthSize returns the size of NALU length prefix in bytes from the sequence header, or 4 if there is none.
+func (v *VideoTrack) NALULengthSize() int {
+	if v.Config == nil {
+		return 4
+	}
+	return v.Config.NALULengthSize
+}
+
+// VideoTrack returns the video decoding context from the last AVC sequence header returned by ReadTag.
+// Sequence headers larger than the read buffer are retained only by FirstKeyframe.
+// The result is not changed by reading, another one is returned after the next sequence header.
+func (r *Reader) VideoTrack() *VideoTrack {
+	if r.track == nil {
+		r.track = &VideoTrack{}
+		if len(r.seq) > 0 {
+			r.track.Config, _ = ParseAVCDecoderConfig(bytes.NewReader(r.seq))
+		}
+	}
+	return r.track
+}
+
+// peekSequenceHeader retains the payload of the video tag t if it is AVC sequence header fitting in the buffer.
+func (r *Reader) peekSequenceHeader(t *Tag) {
+	b, _ := r.b.Peek(min(t.Size, 2))
+	if sequenceHeader(t, b) != 0 || b[0]&0x80 != 0 || t.Size < 5 || t.Size > r.b.Size() {
+		return
+	}
+	if b, _ = r.b.Peek(t.Size); len(b) == t.Size {
+		r.setSequenceHeader(b[5:])
+	}
+}
+
+func (r *Reader) setSequenceHeader(b []byte) {
+	if r.track == nil || !bytes.Equal(b, r.seq) {
+		r.seq, r.track = append(r.seq[:0], b...), nil
+	}
+}
+
+// ParseAVCDecoderConfig reads AVCDecoderConfigurationRecord from r.
+func ParseAVCDecoderConfig(r io.Reader) (*AVCDecoderConfig, error) {
+	var b [6]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return nil, err
+	}
+	if b[0] != 1 {
+		return nil, errUnsupportedAVC
+	}
+	c := &AVCDecoderConfig{
+		Version:              b[0],
+		Profile:              b[1],
+		ProfileCompatibility: b[2],
+		Level:                b[3],
+		NALULengthSize:       int(b[4]&3) + 1,
+	}
+	var err error
+	if c.SPS, err = readParameterSets(r, int(b[5]&0x1f)); err != nil {
+		return nil, err
+	}
+	if _, err = io.ReadFull(r, b[:1]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if c.PPS, err = readParameterSets(r, int(b[0])); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func readParameterSets(r io.Reader, n int) ([][]byte, error) {
+	var b [2]byte
+	sets := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		p := make([]byte, int(b[0])<<8|int(b[1]))
+		if _, err := io.ReadFull(r, p); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		sets = append(sets, p)
+	}
+	return sets, nil
+}
+
+// AVCCToAnnexB returns a reader that converts length-prefixed NALUs read from r
+// into Annex-B byte stream by replacing each length prefix with a 4-byte start code.
+// The naluLengthSize is usually taken from AVCDecoderConfig.
+func AVCCToAnnexB(r io.Reader, naluLengthSize int) (io.Reader, error) {
+	if naluLengthSize < 1 || naluLengthSize > 4 {
+		return nil, errNALULengthSize
+	}
+	return &annexBReader{r: r, size: naluLengthSize}, nil
+}
+
+type annexBReader struct {
+	r    io.Reader
+	size int
+	code int   // remaining bytes of the start code
+	n    int64 // remaining bytes of the current NALU
+	buf  [4]byte
+}
+
+func (r *annexBReader) Read(p []byte) (total int, err error) {
+	for len(p) > 0 {
+		if r.code == 0 && r.n == 0 {
+			b := r.buf[:r.size]
+			if _, err = io.ReadFull(r.r, b); err != nil {
+				if err == io.EOF && total > 0 {
+					err = nil
+				}
+				return
+			}
+			r.n = 0
+			for _, c := range b {
+				r.n = r.n<<8 | int64(c)
+			}
+			r.code = len(startCode)
+		}
+		if r.code > 0 {
+			n := copy(p, startCode[len(startCode)-r.code:])
+			r.code -= n
+			p = p[n:]
+			total += n
+			continue
+		}
+		q := p
+		if int64(len(q)) > r.n {
+			q = q[:r.n]
+		}
+		var n int
+		n, err = r.r.Read(q)
+		r.n -= int64(n)
+		p = p[n:]
+		total += n
+		if err == io.EOF && r.n == 0 {
+			err = nil
+		}
+		if err != nil {
+			return total, unexpectedEOF(err)
+		}
+	}
+	return
+}
+
+// ParseSPSResolution decodes the visual dimensions of the picture from the sequence parameter set NAL unit,
+// such as AVCDecoderConfig.SPS, taking the frame cropping into account.
+func ParseSPSResolution(sps []byte) (width, height int, err error) {
+	if len(sps) < 4 || sps[0]&0x1f != 7 {
+		return 0, 0, errInvalidSPS
+	}
+	r := &spsReader{bitReader: bitReader{b: removeEmulationPrevention(sps[1:])}}
+	profile := r.u(8)
+	r.u(16) // constraint flags and level
+	r.ue()  // seq_parameter_set_id
+	chroma, separate := uint32(1), uint32(0)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		if chroma = r.ue(); chroma == 3 {
+			separate = r.u(1)
+		}
+		r.ue() // bit_depth_luma_minus8
+		r.ue() // bit_depth_chroma_minus8
+		r.u(1) // qpprime_y_zero_transform_bypass_flag
+		if r.u(1) == 1 {
+			n := 8
+			if chroma == 3 {
+				n = 12
+			}
+			for i := 0; i < n; i++ {
+				if r.u(1) == 1 {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					r.skipScalingList(size)
+				}
+			}
+		}
+	}
+	r.ue() // log2_max_frame_num_minus4
+	switch r.ue() {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.u(1) // delta_pic_order_always_zero_flag
+		r.se() // offset_for_non_ref_pic
+		r.se() // offset_for_top_to_bottom_field
+		n := r.ue()
+		for i := uint32(0); i < n && r.err == nil; i++ {
+			r.se() // offset_for_ref_frame
+		}
+	}
+	r.ue() // max_num_ref_frames
+	r.u(1) // gaps_in_frame_num_value_allowed_flag
+	w := r.ue() + 1
+	h := r.ue() + 1
+	frameMBsOnly := r.u(1)
+	if frameMBsOnly == 0 {
+		r.u(1) // mb_adaptive_frame_field_flag
+	}
+	r.u(1) // direct_8x8_inference_flag
+	var left, right, top, bottom uint32
+	if r.u(1) == 1 {
+		left, right, top, bottom = r.ue(), r.ue(), r.ue(), r.ue()
+	}
+	if r.err != nil {
+		return 0, 0, r.err
+	}
+	// Crop units depend on chroma subsampling, for 4:2:0 they are 2x2 samples.
+	unitX, unitY := uint32(1), 2-frameMBsOnly
+	if separate == 0 && chroma != 0 {
+		if chroma != 3 {
+			unitX = 2
+		}
+		if chroma == 1 {
+			unitY *= 2
+		}
+	}
+	width = int(w*16) - int((left+right)*unitX)
+	height = int((2-frameMBsOnly)*h*16) - int((top+bottom)*unitY)
+	if w > 1024 || h > 1024 || width <= 0 || height <= 0 {
+		return 0, 0, errInvalidSPS
+	}
+	return width, height, nil
+}
+
+// spsReader is a bitReader keeping the first error.
+type spsReader struct {
+	bitReader
+	err error
+}
+
+func (r *spsReader) u(n int) uint32 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := r.readBits(n)
+	r.err = err
+	return v
+}
+
+func (r *spsReader) ue() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := r.readUE()
+	r.err = err
+	return v
+}
+
+func (r *spsReader) se() int32 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := r.readSE()
+	r.err = err
+	return v
+}
+
+func (r *spsReader) skipScalingList(size int) {
+	last, next := int32(8), int32(8)
+	for i := 0; i < size && r.err == nil; i++ {
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
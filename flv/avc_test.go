@@ -0,0 +1,161 @@
+package flv
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+var (
+	testSPS = []byte{0x67, 0x64, 0x00, 0x1f, 0xac, 0xd9, 0x40, 0x50, 0x05, 0xbb, 0x01, 0x10, 0x00, 0x00, 0x03, 0x00, 0x10, 0x00, 0x00, 0x03, 0x03, 0xc0, 0xf1, 0x83, 0x19, 0x60}
+	testPPS = []byte{0x68, 0xeb, 0xe3, 0xcb, 0x22, 0xc0}
+)
+
+// AVCDecoderConfigurationRecord of x264 encoded 1280x720 stream.
+var testAVCConfig = append(append(append([]byte{0x01, 0x64, 0x00, 0x1f, 0xff, 0xe1, 0x00, byte(len(testSPS))}, testSPS...), 0x01, 0x00, byte(len(testPPS))), testPPS...)
+
+func TestParseAVCDecoderConfig(t *testing.T) {
+	c, err := ParseAVCDecoderConfig(bytes.NewReader(testAVCConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &AVCDecoderConfig{
+		Version:              1,
+		Profile:              100,
+		ProfileCompatibility: 0,
+		Level:                31,
+		NALULengthSize:       4,
+		SPS:                  [][]byte{testSPS},
+		PPS:                  [][]byte{testPPS},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("got: %#v, expected: %#v", c, expected)
+	}
+}
+
+func TestParseAVCDecoderConfigMulti(t *testing.T) {
+	b := []byte{0x01, 0x42, 0xc0, 0x1e, 0xfd, 0xe2, 0x00, 0x02, 0x67, 0x42, 0x00, 0x03, 0x67, 0x42, 0x01, 0x03, 0x00, 0x01, 0x68, 0x00, 0x01, 0x69, 0x00, 0x02, 0x68, 0xce}
+	c, err := ParseAVCDecoderConfig(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.NALULengthSize != 2 || len(c.SPS) != 2 || len(c.PPS) != 3 || !bytes.Equal(c.SPS[1], []byte{0x67, 0x42, 0x01}) || !bytes.Equal(c.PPS[2], []byte{0x68, 0xce}) {
+		t.Errorf("got: %#v", c)
+	}
+	if _, err = ParseAVCDecoderConfig(bytes.NewReader(b[:12])); err != io.ErrUnexpectedEOF {
+		t.Errorf("got: %v, expected: %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestAVCCToAnnexB(t *testing.T) {
+	nalus := [][]byte{{0x09, 0xf0}, {0x65, 0x88, 0x84, 0x00, 0x33}, {0x06}}
+	for _, size := range []int{1, 2, 4} {
+		var in, expected []byte
+		for _, it := range nalus {
+			p := make([]byte, 4)
+			putUint32(p, uint32(len(it)))
+			in = append(append(in, p[4-size:]...), it...)
+			expected = append(append(expected, 0, 0, 0, 1), it...)
+		}
+		for _, it := range []io.Reader{bytes.NewReader(in), iotest.OneByteReader(bytes.NewReader(in))} {
+			r, err := AVCCToAnnexB(it, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, expected) {
+				t.Errorf("size %d: got: %x, expected: %x", size, out, expected)
+			}
+		}
+		r, _ := AVCCToAnnexB(bytes.NewReader(in[:len(in)-1]), size)
+		if _, err := io.ReadAll(r); err != io.ErrUnexpectedEOF {
+			t.Errorf("got: %v, expected: %v", err, io.ErrUnexpectedEOF)
+		}
+	}
+	if _, err := AVCCToAnnexB(nil, 3); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := AVCCToAnnexB(nil, 5); err != errNALULengthSize {
+		t.Errorf("got: %v, expected: %v", err, errNALULengthSize)
+	}
+}
+
+func TestParseSPSResolution(t *testing.T) {
+	for _, it := range []struct {
+		sps           []byte
+		width, height int
+	}{
+		{testSPS, 1280, 720},
+		// High profile 1920x1080 with frame cropping of 8 bottom lines.
+		{[]byte{0x67, 0x64, 0x00, 0x28, 0xac, 0xd9, 0x40, 0x78, 0x02, 0x27, 0xe5, 0x40}, 1920, 1080},
+		// Interlaced 1920x1080 coded as 34 field macroblock pairs.
+		{[]byte{0x67, 0x64, 0x00, 0x1e, 0xac, 0xd9, 0x40, 0x78, 0x04, 0x47, 0xda}, 1920, 1080},
+		// Baseline profile 320x240.
+		{[]byte{0x67, 0x42, 0x00, 0x1e, 0xec, 0xa0, 0xa0, 0xfc, 0x80}, 320, 240},
+	} {
+		w, h, err := ParseSPSResolution(it.sps)
+		if err != nil {
+			t.Fatalf("%v: %x", err, it.sps)
+		}
+		if w != it.width || h != it.height {
+			t.Errorf("got: %dx%d, expected: %dx%d", w, h, it.width, it.height)
+		}
+	}
+	if b := removeEmulationPrevention([]byte{0, 0, 3, 1, 0, 0, 3, 0, 3}); !bytes.Equal(b, []byte{0, 0, 1, 0, 0, 0, 3}) {
+		t.Errorf("got rbsp: %x", b)
+	}
+	for _, sps := range [][]byte{nil, testPPS, testSPS[:8]} {
+		if _, _, err := ParseSPSResolution(sps); err == nil {
+			t.Errorf("no error for %x", sps)
+		}
+	}
+}
+
+func TestReaderVideoTrack(t *testing.T) {
+	config := append([]byte{}, testAVCConfig...)
+	config[4] = 0xfd // 2-byte NALU length prefix
+	in := buildFLV(5,
+		testTags[0],
+		testTag{TagTypeVideo, 0, append([]byte{0x17, 0, 0, 0, 0}, config...)},
+		testTag{TagTypeVideo, 0, []byte{0x17, 1, 0, 0, 0, 0, 2, 0x65, 0x88}},
+	)
+	r := NewReader(bytes.NewReader(in))
+	if n := r.VideoTrack().NALULengthSize(); n != 4 {
+		t.Errorf("got default: %d, expected: 4", n)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, err := r.ReadTag(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v := r.VideoTrack()
+	if v.Config == nil || v.NALULengthSize() != 2 {
+		t.Fatalf("got: %+v, expected NALU length size 2", v.Config)
+	}
+	_, data, err := r.ReadTag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err = ParseVideoHeader(data); err != nil {
+		t.Fatal(err)
+	}
+	nalus, err := AVCCToAnnexB(data, v.NALULengthSize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(nalus); !bytes.Equal(b, []byte{0, 0, 0, 1, 0x65, 0x88}) {
+		t.Errorf("got: %x", b)
+	}
+	if r.VideoTrack() != v {
+		t.Error("video track is changed without sequence header")
+	}
+	r.Reset(bytes.NewReader(in))
+	if r.VideoTrack().Config != nil {
+		t.Error("got video track after Reset")
+	}
+}
@@ -0,0 +1,73 @@
+package flv
+
+import (
+	"errors"
+	"io"
+)
+
+var errInvalidExpGolomb = errors.New("flv: invalid exp-golomb code")
+
+// bitReader reads MSB-first bit fields from a byte slice.
+type bitReader struct {
+	b   []byte
+	pos int // position in bits
+}
+
+func (r *bitReader) readBits(n int) (uint32, error) {
+	if r.pos+n > len(r.b)<<3 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	var v uint32
+	for i := 0; i < n; i++ {
+		v = v<<1 | uint32(r.b[r.pos>>3]>>(7-r.pos&7)&1)
+		r.pos++
+	}
+	return v, nil
+}
+
+// readUE reads unsigned Exp-Golomb code.
+func (r *bitReader) readUE() (uint32, error) {
+	n := 0
+	for {
+		b, err := r.readBits(1)
+		if err != nil {
+			return 0, err
+		}
+		if b == 1 {
+			break
+		}
+		if n++; n > 31 {
+			return 0, errInvalidExpGolomb
+		}
+	}
+	v, err := r.readBits(n)
+	return 1<<n - 1 + v, err
+}
+
+// readSE reads signed Exp-Golomb code.
+func (r *bitReader) readSE() (int32, error) {
+	v, err := r.readUE()
+	if v&1 == 0 {
+		return -int32(v >> 1), err
+	}
+	return int32(v>>1) + 1, err
+}
+
+// removeEmulationPrevention returns RBSP of NAL unit payload b with emulation prevention bytes removed.
+func removeEmulationPrevention(b []byte) []byte {
+	rbsp := make([]byte, 0, len(b))
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c == 3 {
+			zeros = 0
+			continue
+		}
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		rbsp = append(rbsp, c)
+	}
+	return rbsp
+}
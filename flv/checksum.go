@@ -0,0 +1,64 @@
+package flv
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// ErrChecksum is returned by VerifyChecksums if a tag does not match its checksum.
+var ErrChecksum = errors.New("flv: checksum mismatch")
+
+var errChecksumIndex = errors.New("flv: invalid checksum entry")
+
+// Checksum sidecar consists of 8-byte entries, one per tag: the tag index counted from zero
+// followed by CRC-32 (IEEE) of the tag header and payload, both big-endian.
+const checksumSize = 8
+
+// writeChecksum writes the checksum entry of the i-th tag with header and payload in b.
+func writeChecksum(w io.Writer, i int, b []byte) error {
+	var e [checksumSize]byte
+	putUint32(e[:], uint32(i))
+	putUint32(e[4:], crc32.ChecksumIEEE(b))
+	_, err := w.Write(e[:])
+	return err
+}
+
+// VerifyChecksums reads the header unless it is already read and the remaining tags,
+// and compares them with the checksum entries read from side, as written by Writer to ChecksumWriter.
+// It returns an error wrapping ErrChecksum for the first tag not matching its entry.
+// The tags are counted from the current position, so the checksums must be of the tags from there on.
+func (r *Reader) VerifyChecksums(side io.Reader) error {
+	var e [checksumSize]byte
+	var h [11]byte
+	var tag Tag
+	crc := crc32.NewIEEE()
+	for i := 0; ; i++ {
+		data, err := r.ReadTagInto(&tag)
+		if err == io.EOF {
+			if _, err = io.ReadFull(side, e[:]); err != io.EOF {
+				return fmt.Errorf("%w: entry %d after the last tag", errChecksumIndex, i)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err = io.ReadFull(side, e[:]); err != nil {
+			return fmt.Errorf("%w: tag %d: %w", errChecksumIndex, i, unexpectedEOF(err))
+		}
+		if int(getUint32(e[:])) != i {
+			return fmt.Errorf("%w: index %d for tag %d", errChecksumIndex, getUint32(e[:]), i)
+		}
+		tag.putHeader(h[:])
+		crc.Reset()
+		crc.Write(h[:])
+		if _, err = io.Copy(crc, data); err != nil {
+			return err
+		}
+		if crc.Sum32() != getUint32(e[4:]) {
+			return fmt.Errorf("%w: tag %d %s", ErrChecksum, i, &tag)
+		}
+	}
+}
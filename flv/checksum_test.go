@@ -0,0 +1,48 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	out, side := &bytes.Buffer{}, &bytes.Buffer{}
+	w := NewWriter(out)
+	w.ChecksumWriter = side
+	if err := w.WriteHeader(NewHeader(true, true)); err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range testTags {
+		if err := w.WriteTag(&Tag{Type: it.typ, Time: it.time}, bytes.NewReader(it.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if in := buildFLV(5, testTags...); !bytes.Equal(out.Bytes(), in) {
+		t.Fatalf("got: %x, expected: %x", out.Bytes(), in)
+	}
+	if side.Len() != len(testTags)*checksumSize {
+		t.Fatalf("got %d bytes of checksums, expected %d", side.Len(), len(testTags)*checksumSize)
+	}
+	if err := NewReader(bytes.NewReader(out.Bytes())).VerifyChecksums(bytes.NewReader(side.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	// Corrupt the payload and the timestamp of the fourth tag.
+	for _, p := range []int{13 + 11, 13 + 4} {
+		p += 11 + len(testTags[0].data) + 4 + 11 + len(testTags[1].data) + 4 + 11 + len(testTags[2].data) + 4
+		in := append([]byte{}, out.Bytes()...)
+		in[p] ^= 1
+		err := NewReader(bytes.NewReader(in)).VerifyChecksums(bytes.NewReader(side.Bytes()))
+		if !errors.Is(err, ErrChecksum) {
+			t.Errorf("got: %v, expected: %v", err, ErrChecksum)
+		}
+	}
+	err := NewReader(bytes.NewReader(out.Bytes())).VerifyChecksums(bytes.NewReader(side.Bytes()[checksumSize:]))
+	if !errors.Is(err, errChecksumIndex) {
+		t.Errorf("got: %v, expected: %v", err, errChecksumIndex)
+	}
+	err = NewReader(bytes.NewReader(out.Bytes())).VerifyChecksums(bytes.NewReader(side.Bytes()[:checksumSize]))
+	if !errors.Is(err, errChecksumIndex) {
+		t.Errorf("got: %v, expected: %v", err, errChecksumIndex)
+	}
+}
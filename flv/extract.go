@@ -0,0 +1,293 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var errNoSequenceHeader = errors.New("flv: missing sequence header")
+
+// ExtractVideo reads the header unless it is already read and the remaining tags,
+// and writes AVC video as Annex-B byte stream to w.
+// Parameter sets of the sequence headers are written in place of them.
+func (r *Reader) ExtractVideo(w io.Writer) error {
+	size := 0
+	return r.Walk(func(tag *Tag, data io.Reader) error {
+		if tag.Type != TagTypeVideo {
+			return nil
+		}
+		h, data, err := ParseVideoHeader(data)
+		if err != nil {
+			return err
+		}
+		if h.Enhanced || h.CodecID != CodecIDAVC {
+			return fmt.Errorf("%w: codec %d", errUnsupportedVideo, h.CodecID)
+		}
+		switch h.AVCPacketType {
+		case AVCPacketTypeSequenceHeader:
+			c, err := ParseAVCDecoderConfig(data)
+			if err != nil {
+				return err
+			}
+			size = c.NALULengthSize
+			for _, p := range append(c.SPS, c.PPS...) {
+				if _, err = w.Write(startCode); err != nil {
+					return err
+				}
+				if _, err = w.Write(p); err != nil {
+					return err
+				}
+			}
+		case AVCPacketTypeNALU:
+			if size == 0 {
+				return errNoSequenceHeader
+			}
+			nalus, err := AVCCToAnnexB(data, size)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(w, nalus)
+			return err
+		}
+		return nil
+	})
+}
+
+// FirstKeyframe reads the header unless it is already read and returns the first video tag
+// containing a keyframe together with its payload, including the video tag header.
+// Audio, script and other video tags are skipped, but the AVC sequence header is retained,
+// so that the frame can be converted to Annex-B using AVCCToAnnexB and VideoTrack.
+// It returns io.EOF if there is no keyframe.
+func (r *Reader) FirstKeyframe() (*Tag, []byte, error) {
+	for {
+		tag, data, err := r.ReadTag()
+		if err != nil {
+			return nil, nil, err
+		}
+		if tag.Type != TagTypeVideo {
+			continue
+		}
+		b := make([]byte, tag.Size)
+		if _, err = io.ReadFull(data, b); err != nil {
+			return nil, nil, err
+		}
+		h, _, err := ParseVideoHeader(bytes.NewReader(b))
+		if err != nil {
+			return nil, nil, err
+		}
+		if !h.IsKeyframe() {
+			continue
+		}
+		if sequenceHeader(tag, b) == 0 {
+			if !h.Enhanced {
+				// It may be too large to be retained by ReadTag.
+				r.setSequenceHeader(b[5:])
+			}
+			continue
+		}
+		if h.isFrame() {
+			return tag, b, nil
+		}
+	}
+}
+
+// AVCConfig returns the AVC sequence header preceding the keyframe returned by FirstKeyframe,
+// or nil if there is none. It is the same as VideoTrack().Config.
+func (r *Reader) AVCConfig() *AVCDecoderConfig {
+	return r.VideoTrack().Config
+}
+
+// ExtractAudio reads the header unless it is already read and the remaining tags,
+// and writes audio elementary stream to w. AAC is written as ADTS stream with headers
+// built from AudioSpecificConfig of the sequence headers, MP3 as raw frames, Speex as Ogg Speex stream.
+// The sound format must not change within the stream.
+func (r *Reader) ExtractAudio(w io.Writer) error {
+	var format SoundFormat
+	var cfg *AudioSpecificConfig
+	var speex *speexWriter
+	var buf bytes.Buffer
+	n := 0
+	err := r.Walk(func(tag *Tag, data io.Reader) error {
+		if tag.Type != TagTypeAudio {
+			return nil
+		}
+		h, data, err := ParseAudioHeader(data)
+		if err != nil {
+			return err
+		}
+		if n++; n == 1 {
+			format = h.Format
+		} else if h.Format != format {
+			return fmt.Errorf("%w: %s after %s", errUnsupportedAudio, h.Format, format)
+		}
+		switch h.Format {
+		case SoundFormatAAC:
+			if h.AACPacketType == AACPacketTypeSequenceHeader {
+				cfg, err = ParseAudioSpecificConfig(data)
+				return err
+			}
+			if cfg == nil {
+				return errNoSequenceHeader
+			}
+			buf.Reset()
+			if _, err = buf.ReadFrom(data); err != nil {
+				return err
+			}
+			adts, err := BuildADTSHeader(cfg, buf.Len())
+			if err != nil {
+				return err
+			}
+			if _, err = w.Write(adts[:]); err != nil {
+				return err
+			}
+			_, err = w.Write(buf.Bytes())
+			return err
+		case SoundFormatMP3, SoundFormatMP38kHz:
+			// MP3 frames are self-delimiting.
+			_, err = io.Copy(w, data)
+			return err
+		case SoundFormatSpeex:
+			if speex == nil {
+				if speex, err = newSpeexWriter(w); err != nil {
+					return err
+				}
+			}
+			buf.Reset()
+			if _, err = buf.ReadFrom(data); err != nil {
+				return err
+			}
+			return speex.writeFrame(tag.Time, buf.Bytes())
+		}
+		return fmt.Errorf("%w: %s", errUnsupportedAudio, h.Format)
+	})
+	if err == nil && speex != nil {
+		err = speex.close()
+	}
+	return err
+}
+
+// AudioTimestamps reads the header unless it is already read and the remaining tags,
+// and returns timestamps in milliseconds of AAC frames computed from the number of 1024-sample frames
+// since the first frame following the sequence header, instead of the rounded tag timestamps which drift.
+// The count restarts after every sequence header. If sampleRate is zero, the rate of the sequence header is used.
+func (r *Reader) AudioTimestamps(sampleRate int) ([]int64, error) {
+	var ts []int64
+	rate, base, n := sampleRate, int64(0), int64(0)
+	seq := false
+	err := r.Walk(func(tag *Tag, data io.Reader) error {
+		if tag.Type != TagTypeAudio {
+			return nil
+		}
+		h, data, err := ParseAudioHeader(data)
+		if err != nil {
+			return err
+		}
+		if h.Format != SoundFormatAAC {
+			return fmt.Errorf("%w: %s", errUnsupportedAudio, h.Format)
+		}
+		if h.AACPacketType == AACPacketTypeSequenceHeader {
+			cfg, err := ParseAudioSpecificConfig(data)
+			if err != nil {
+				return err
+			}
+			if sampleRate == 0 {
+				rate = cfg.SampleRate
+			}
+			if rate <= 0 {
+				return errInvalidAAC
+			}
+			seq, n = true, 0
+			return nil
+		}
+		if !seq {
+			return errNoSequenceHeader
+		}
+		if n == 0 {
+			base = tag.Time
+		}
+		ts = append(ts, base+(n*1024*1000+int64(rate)/2)/int64(rate))
+		n++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// captionDuration is the longest duration in milliseconds of a caption cue written by ExtractCaptions.
+const captionDuration = 3000
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// ExtractCaptions reads the header unless it is already read and the remaining tags,
+// and writes captions of onTextData and onCaption script tags to w as WebVTT.
+// The caption is the text property of the script value, the other properties such as type are ignored.
+// A cue starts at the tag timestamp and lasts until the next caption, at most 3 seconds.
+// An empty text ends the previous cue.
+func (r *Reader) ExtractCaptions(w io.Writer) error {
+	if _, err := io.WriteString(w, "WEBVTT\n"); err != nil {
+		return err
+	}
+	text := ""
+	var start int64
+	cue := func(end int64) error {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		end = min(end, start+captionDuration)
+		_, err := fmt.Fprintf(w, "\n%s --> %s\n%s\n", vttTime(start), vttTime(end), vttText(text))
+		text = ""
+		return err
+	}
+	var buf bytes.Buffer
+	err := r.Walk(func(tag *Tag, data io.Reader) error {
+		if tag.Type != TagTypeScript {
+			return nil
+		}
+		buf.Reset()
+		if _, err := buf.ReadFrom(data); err != nil {
+			return err
+		}
+		name, v, err := decodeScriptData(&buf)
+		if err != nil || name != "onTextData" && name != "onCaption" {
+			// Other script tags are not captions.
+			return nil
+		}
+		s, ok := v.(string)
+		if m, isMap := v.(map[string]interface{}); isMap {
+			s, ok = m["text"].(string)
+		}
+		if !ok {
+			return nil
+		}
+		if err = cue(tag.Time); err != nil {
+			return err
+		}
+		text, start = s, tag.Time
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return cue(start + captionDuration)
+}
+
+// vttTime formats milliseconds as WebVTT timestamp.
+func vttTime(ms int64) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// vttText escapes caption text for WebVTT cue payload, which must not contain blank lines.
+func vttText(s string) string {
+	var lines []string
+	for _, l := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, vttEscaper.Replace(l))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
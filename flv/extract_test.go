@@ -0,0 +1,273 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestReaderExtractVideo(t *testing.T) {
+	in := buildFLV(5,
+		testTags[0],
+		testTag{TagTypeVideo, 0, append([]byte{0x17, 0, 0, 0, 0}, testAVCConfig...)},
+		testTag{TagTypeAudio, 0, []byte{0xaf, 0, 0x12, 0x10}},
+		testTag{TagTypeVideo, 0, []byte{0x17, 1, 0, 0, 0, 0, 0, 0, 2, 0x65, 0x88, 0, 0, 0, 1, 0x06}},
+		testTag{TagTypeVideo, 40, []byte{0x27, 1, 0, 0, 0, 0, 0, 0, 1, 0x41}},
+		testTag{TagTypeVideo, 80, []byte{0x17, 2, 0, 0, 0}},
+	)
+	out := &bytes.Buffer{}
+	if err := NewReader(bytes.NewReader(in)).ExtractVideo(out); err != nil {
+		t.Fatal(err)
+	}
+	var expected []byte
+	for _, p := range [][]byte{testSPS, testPPS, {0x65, 0x88}, {0x06}, {0x41}} {
+		expected = append(append(expected, 0, 0, 0, 1), p...)
+	}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("got: %x, expected: %x", out.Bytes(), expected)
+	}
+	in = buildFLV(5, testTag{TagTypeVideo, 40, []byte{0x27, 1, 0, 0, 0, 0, 0, 0, 1, 0x41}})
+	if err := NewReader(bytes.NewReader(in)).ExtractVideo(out); err != errNoSequenceHeader {
+		t.Errorf("got: %v, expected: %v", err, errNoSequenceHeader)
+	}
+	in = buildFLV(5, testTag{TagTypeVideo, 0, []byte{0x22, 0, 0}})
+	if err := NewReader(bytes.NewReader(in)).ExtractVideo(out); !errors.Is(err, errUnsupportedVideo) {
+		t.Errorf("got: %v, expected: %v", err, errUnsupportedVideo)
+	}
+}
+
+func TestReaderExtractAudio(t *testing.T) {
+	in := buildFLV(5,
+		testTags[0],
+		testTag{TagTypeAudio, 0, []byte{0xaf, 0, 0x12, 0x10}},
+		testTag{TagTypeVideo, 0, []byte{0x17, 2, 0, 0, 0}},
+		testTag{TagTypeAudio, 0, []byte{0xaf, 1, 0x21, 0x10, 0x04}},
+		testTag{TagTypeAudio, 23, []byte{0xaf, 1, 0x21}},
+	)
+	out := &bytes.Buffer{}
+	if err := NewReader(bytes.NewReader(in)).ExtractAudio(out); err != nil {
+		t.Fatal(err)
+	}
+	// Reference ADTS stream of LC 44100 Hz stereo frames of 10 and 8 bytes including headers.
+	expected := []byte{
+		0xff, 0xf1, 0x50, 0x80, 0x01, 0x5f, 0xfc, 0x21, 0x10, 0x04,
+		0xff, 0xf1, 0x50, 0x80, 0x01, 0x1f, 0xfc, 0x21,
+	}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("got: %x, expected: %x", out.Bytes(), expected)
+	}
+	in = buildFLV(5, testTag{TagTypeAudio, 0, []byte{0xaf, 1, 0x21}})
+	if err := NewReader(bytes.NewReader(in)).ExtractAudio(out); err != errNoSequenceHeader {
+		t.Errorf("got: %v, expected: %v", err, errNoSequenceHeader)
+	}
+}
+
+func TestReaderExtractMP3(t *testing.T) {
+	frame := func(b byte) []byte {
+		f := make([]byte, 1+417)
+		f[0], f[1], f[2], f[3] = 0x2f, 0xff, 0xfb, 0x90
+		f[len(f)-1] = b
+		return f
+	}
+	in := buildFLV(4, testTags[0], testTag{TagTypeAudio, 0, frame(1)}, testTag{TagTypeAudio, 26, frame(2)}, testTag{TagTypeAudio, 52, frame(3)})
+	out := &bytes.Buffer{}
+	if err := NewReader(bytes.NewReader(in)).ExtractAudio(out); err != nil {
+		t.Fatal(err)
+	}
+	b := out.Bytes()
+	if len(b) != 3*417 {
+		t.Fatalf("got %d bytes, expected %d", len(b), 3*417)
+	}
+	for i := 0; i < 3; i++ {
+		f := b[i*417:]
+		if f[0] != 0xff || f[1]&0xe0 != 0xe0 || f[416] != byte(i+1) {
+			t.Errorf("frame %d: got: %x", i, f[:4])
+		}
+	}
+	in = buildFLV(4, testTag{TagTypeAudio, 0, frame(1)}, testTags[2])
+	if err := NewReader(bytes.NewReader(in)).ExtractAudio(out); !errors.Is(err, errUnsupportedAudio) {
+		t.Errorf("got: %v, expected: %v", err, errUnsupportedAudio)
+	}
+}
+
+func TestReaderExtractSpeex(t *testing.T) {
+	if crc := oggCRC([]byte("123456789")); crc != 0x89a1897f {
+		t.Fatalf("got crc: %08x", crc)
+	}
+	frames := [][]byte{{1, 2, 3}, bytes.Repeat([]byte{4}, 300), {5}}
+	in := buildFLV(4,
+		testTag{TagTypeAudio, 0, append([]byte{0xb2}, frames[0]...)},
+		testTag{TagTypeAudio, 20, append([]byte{0xb2}, frames[1]...)},
+		testTag{TagTypeAudio, 40, append([]byte{0xb2}, frames[2]...)},
+	)
+	out := &bytes.Buffer{}
+	if err := NewReader(bytes.NewReader(in)).ExtractAudio(out); err != nil {
+		t.Fatal(err)
+	}
+	b := out.Bytes()
+	var packets [][]byte
+	for i := 0; len(b) > 0; i++ {
+		if len(b) < 27 || string(b[:4]) != "OggS" {
+			t.Fatalf("page %d: no capture pattern: %x", i, b)
+		}
+		n := int(b[26])
+		size := 0
+		for _, s := range b[27 : 27+n] {
+			size += int(s)
+		}
+		page := append([]byte{}, b[:27+n+size]...)
+		crc := getUint32([]byte{page[25], page[24], page[23], page[22]})
+		page[22], page[23], page[24], page[25] = 0, 0, 0, 0
+		if oggCRC(page) != crc {
+			t.Errorf("page %d: crc mismatch", i)
+		}
+		if seq := int(page[18]); seq != i {
+			t.Errorf("page %d: got sequence number %d", i, seq)
+		}
+		if flags := page[5]; i == 0 && flags != oggBOS || i == 4 && flags != oggEOS || i > 0 && i < 4 && flags != 0 {
+			t.Errorf("page %d: got flags %d", i, flags)
+		}
+		if i >= 2 {
+			granule := int64(page[6]) | int64(page[7])<<8
+			if expected := int64(i-1) * 320; granule != expected {
+				t.Errorf("page %d: got granule %d, expected %d", i, granule, expected)
+			}
+		}
+		packets = append(packets, page[27+n:])
+		b = b[len(page):]
+	}
+	if len(packets) != 5 {
+		t.Fatalf("got %d packets, expected 5", len(packets))
+	}
+	if h := packets[0]; len(h) != 80 || string(h[:8]) != "Speex   " {
+		t.Errorf("got speex header: %q", h)
+	}
+	for i, f := range frames {
+		if !bytes.Equal(packets[i+2], f) {
+			t.Errorf("frame %d: got: %x, expected: %x", i, packets[i+2], f)
+		}
+	}
+}
+
+func TestReaderFirstKeyframe(t *testing.T) {
+	key := []byte{0x17, 1, 0, 0, 0, 0, 0, 0, 2, 0x65, 0x88}
+	in := buildFLV(5,
+		testTags[0],
+		testTag{TagTypeAudio, 0, []byte{0xaf, 0, 0x12, 0x10}},
+		testTag{TagTypeVideo, 0, append([]byte{0x17, 0, 0, 0, 0}, testAVCConfig...)},
+		testTag{TagTypeVideo, 0, []byte{0x27, 1, 0, 0, 0, 0, 0, 0, 1, 0x41}},
+		// Enhanced metadata packet and end of sequence with the key frame type carry no frame.
+		testTag{TagTypeVideo, 20, []byte{0x90 | PacketTypeMetadata, 'a', 'v', 'c', '1', 2, 0, 9, 'c', 'o', 'l', 'o', 'r', 'I', 'n', 'f', 'o'}},
+		testTag{TagTypeVideo, 30, []byte{0x17, 2, 0, 0, 0}},
+		testTag{TagTypeVideo, 40, key},
+		testTag{TagTypeVideo, 80, []byte{0x17, 1, 0, 0, 0, 0, 0, 0, 1, 0x65}},
+	)
+	r := NewReader(bytes.NewReader(in))
+	tag, b, err := r.FirstKeyframe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Type != TagTypeVideo || tag.Time != 40 || !bytes.Equal(b, key) {
+		t.Errorf("got: %v %x, expected: video@40ms %x", tag, b, key)
+	}
+	h, data, err := ParseVideoHeader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.IsKeyframe() {
+		t.Errorf("got not a keyframe: %#v", h)
+	}
+	c := r.AVCConfig()
+	if c == nil {
+		t.Fatal("sequence header is not retained")
+	}
+	nalus, err := AVCCToAnnexB(data, c.NALULengthSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	if _, err = out.ReadFrom(nalus); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte{0, 0, 0, 1, 0x65, 0x88}; !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("got: %x, expected: %x", out.Bytes(), expected)
+	}
+	in = buildFLV(5, testTags[2], testTag{TagTypeVideo, 0, append([]byte{0x17, 0, 0, 0, 0}, testAVCConfig...)})
+	if _, _, err = NewReader(bytes.NewReader(in)).FirstKeyframe(); err != io.EOF {
+		t.Errorf("got: %v, expected: %v", err, io.EOF)
+	}
+}
+
+func TestReaderAudioTimestamps(t *testing.T) {
+	tags := []testTag{{TagTypeAudio, 0, []byte{0xaf, 0, 0x12, 0x10}}} // 44100 Hz
+	var expected []int64
+	for i := int64(0); i < 100; i++ {
+		tags = append(tags, testTag{TagTypeAudio, i * 1024 * 1000 / 44100, []byte{0xaf, 1, 0}})
+		expected = append(expected, int64(math.Round(float64(i*1024*1000)/44100)))
+	}
+	tags = append(tags, testTag{TagTypeVideo, 2400, []byte{0x27, 1, 0, 0, 0}}, testTag{TagTypeAudio, 5000, []byte{0xaf, 0, 0x11, 0x90}}) // 48000 Hz
+	for i := int64(0); i < 10; i++ {
+		tags = append(tags, testTag{TagTypeAudio, 5000 + i*1024*1000/48000, []byte{0xaf, 1, 0}})
+		expected = append(expected, 5000+int64(math.Round(float64(i*1024*1000)/48000)))
+	}
+	in := buildFLV(5, tags...)
+	ts, err := NewReader(bytes.NewReader(in)).AudioTimestamps(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ts, expected) {
+		t.Errorf("got: %v, expected: %v", ts, expected)
+	}
+	if ts, err = NewReader(bytes.NewReader(in)).AudioTimestamps(1024); err != nil || ts[1] != 1000 || ts[101] != 6000 {
+		t.Errorf("got: %v, %v", ts, err)
+	}
+	if _, err = NewReader(bytes.NewReader(buildFLV(4, tags[1:]...))).AudioTimestamps(0); !errors.Is(err, errNoSequenceHeader) {
+		t.Errorf("got: %v, expected: %v", err, errNoSequenceHeader)
+	}
+}
+
+func TestReaderExtractCaptions(t *testing.T) {
+	text := func(name, s string, typ bool) []byte {
+		b := append([]byte{0x02}, amfString(name)...)
+		b = append(b, 0x08, 0, 0, 0, 2)
+		b = append(b, amfString("text")...)
+		b = append(b, 0x02)
+		b = append(b, amfString(s)...)
+		if typ {
+			b = append(b, amfString("type")...)
+			b = append(b, 0x02)
+			b = append(b, amfString("Text")...)
+		}
+		return append(b, 0, 0, 0x09)
+	}
+	in := buildFLV(5,
+		testTags[0],
+		testTag{TagTypeScript, 1500, text("onTextData", "Hello", true)},
+		testTags[1],
+		testTag{TagTypeScript, 2500, text("onTextData", "a < b\n\nc --> d", false)},
+		testTag{TagTypeScript, 10000, text("onCaption", "", false)},
+		testTag{TagTypeScript, 3723004, text("onCaption", "Bye", false)},
+	)
+	out := &bytes.Buffer{}
+	if err := NewReader(bytes.NewReader(in)).ExtractCaptions(out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `WEBVTT
+
+00:00:01.500 --> 00:00:02.500
+Hello
+
+00:00:02.500 --> 00:00:05.500
+a &lt; b
+c --&gt; d
+
+01:02:03.004 --> 01:02:06.004
+Bye
+`
+	if out.String() != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", out, expected)
+	}
+}
@@ -1,24 +1,191 @@
 package flv
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Header represents FLV file header.
 type Header struct {
-	Flags uint8
+	flags uint8
+
+	// Extra holds nonstandard header data between the 9-byte header and DataOffset.
+	Extra []byte
+
+	// Encrypted reports whether the stream is encrypted. The type flags have no bit for it,
+	// so it is set by Reader on the |AdditionalHeader script tag with encryption parameters
+	// or on the first tag with the filter bit set. It is not encoded by Marshal.
+	Encrypted bool
+}
+
+// Header type flags.
+const (
+	FlagVideo uint8 = 0x01
+	FlagAudio uint8 = 0x04
+)
+
+// NewHeader returns a new header with type flags set for the present tag types.
+func NewHeader(hasAudio, hasVideo bool) *Header {
+	h := &Header{}
+	if hasAudio {
+		h.flags |= FlagAudio
+	}
+	if hasVideo {
+		h.flags |= FlagVideo
+	}
+	return h
+}
+
+// Marshal returns encoding of the header, which is 9 bytes long unless there is Extra data.
+// It does not include the following PreviousTagSize field.
+func (h *Header) Marshal() []byte {
+	return h.append(nil)
+}
+
+func (h *Header) append(b []byte) []byte {
+	b = append(b, 'F', 'L', 'V', 1, h.flags, 0, 0, 0, 0)
+	putUint32(b[len(b)-4:], uint32(9+len(h.Extra)))
+	return append(b, h.Extra...)
+}
+
+// Flags returns raw type flags of the header.
+func (h *Header) Flags() uint8 {
+	return h.flags
 }
 
-func NewHeader(flags uint8) *Header {
-	return &Header{flags}
+// HasVideo reports whether the video tags are present.
+func (h *Header) HasVideo() bool {
+	return h.flags&FlagVideo != 0
 }
 
+// HasAudio reports whether the audio tags are present.
+func (h *Header) HasAudio() bool {
+	return h.flags&FlagAudio != 0
+}
+
+// Tag represents FLV tag header.
 type Tag struct {
 	Type   uint8
 	Size   int
-	Time   int64
+	Time   int64 // timestamp in milliseconds
 	Stream uint32
+
+	// PrevTagSize is the PreviousTagSize field preceding the tag in the stream as read,
+	// it is 0 for the first tag and is not checked unless the reader is strict.
+	PrevTagSize uint32
+
+	// AbsoluteTime is the timestamp in milliseconds of the tag read by Reader or ReaderAt, continuing
+	// after wraparounds if Reader.UnwrapTimestamps is set, otherwise it is the same as Time.
+	AbsoluteTime int64
+}
+
+// Timestamp returns the tag timestamp as a duration.
+func (t *Tag) Timestamp() time.Duration {
+	return time.Duration(t.Time) * time.Millisecond
+}
+
+// SetTimestamp sets the tag timestamp truncated to milliseconds.
+func (t *Tag) SetTimestamp(d time.Duration) {
+	t.Time = int64(d / time.Millisecond)
+}
+
+// Clone returns a copy of the tag header.
+func (t *Tag) Clone() *Tag {
+	c := *t
+	return &c
+}
+
+// Equal reports whether the tag headers have the same type, size, timestamp and stream ID.
+// PrevTagSize is not compared.
+func (t *Tag) Equal(o *Tag) bool {
+	return t.Type == o.Type && t.Size == o.Size && t.Time == o.Time && t.Stream == o.Stream
+}
+
+// TagWithPayload holds the tag header and its payload.
+type TagWithPayload struct {
+	Tag  *Tag
+	Data []byte
+}
+
+// MarshalHeader returns 11-byte encoding of the tag header.
+func (t *Tag) MarshalHeader() []byte {
+	b := make([]byte, 11)
+	t.putHeader(b)
+	return b
+}
+
+func (t *Tag) putHeader(b []byte) {
+	_ = b[10]
+	b[0] = t.Type
+	putUint24(b[1:], uint32(t.Size))
+	putTime(b[4:], t.Time)
+	putUint24(b[8:], t.Stream)
+}
+
+// UnmarshalTagHeader decodes 11-byte tag header from b.
+func UnmarshalTagHeader(b []byte) (*Tag, error) {
+	if len(b) < 11 {
+		return nil, fmt.Errorf("%w: header %d of 11 bytes", ErrShortTag, len(b))
+	}
+	t := &Tag{}
+	t.parseHeader(b)
+	return t, nil
+}
+
+func (t *Tag) parseHeader(b []byte) {
+	_ = b[10]
+	t.Type = b[0]
+	t.Size = getInt24(b[1:])
+	t.Time = getTime(b[4:])
+	t.Stream = getUint24(b[8:])
 }
 
+// Tag types.
+const (
+	TagTypeAudio  uint8 = 8
+	TagTypeVideo  uint8 = 9
+	TagTypeScript uint8 = 18
+)
+
+// TagFilter is the filter bit of the tag type, set for encrypted tags.
+const TagFilter uint8 = 0x20
+
+// additionalHeader is the name of the script tag preceding encrypted tags, encoded as AMF0 string.
+var additionalHeader = []byte("\x02\x00\x11|AdditionalHeader")
+
+// Deprecated: use TagTypeAudio, TagTypeVideo and TagTypeScript instead.
 const (
-	TypeAudio uint8 = 8
-	TypeVideo uint8 = 9
-	TypeData  uint8 = 18
+	TypeAudio = TagTypeAudio
+	TypeVideo = TagTypeVideo
+	TypeData  = TagTypeScript
 )
 
+// String returns the tag type name and timestamp.
+func (t *Tag) String() string {
+	return fmt.Sprintf("%s@%dms", tagTypeName(t.Type), t.Time)
+}
+
+// MarshalJSON encodes the tag header as JSON object with the tag type name.
+func (t *Tag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   string `json:"type"`
+		Size   int    `json:"size"`
+		Time   int64  `json:"time"`
+		Stream uint32 `json:"stream"`
+	}{tagTypeName(t.Type), t.Size, t.Time, t.Stream})
+}
+
+func tagTypeName(t uint8) string {
+	switch t {
+	case TagTypeAudio:
+		return "audio"
+	case TagTypeVideo:
+		return "video"
+	case TagTypeScript:
+		return "script"
+	}
+	return fmt.Sprintf("type(%d)", t)
+}
+
 const signature uint32 = 0x464C56
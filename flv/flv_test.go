@@ -0,0 +1,149 @@
+package flv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHeaderFlags(t *testing.T) {
+	for _, it := range []struct {
+		flags uint8
+		audio bool
+		video bool
+	}{
+		{0x00, false, false},
+		{0x01, false, true},
+		{0x04, true, false},
+		{0x05, true, true},
+	} {
+		h := &Header{flags: it.flags}
+		if h.Flags() != it.flags || h.HasAudio() != it.audio || h.HasVideo() != it.video {
+			t.Errorf("flags 0x%02x: got audio=%v video=%v, expected audio=%v video=%v", it.flags, h.HasAudio(), h.HasVideo(), it.audio, it.video)
+		}
+	}
+}
+
+func TestTagString(t *testing.T) {
+	if TagTypeAudio != 8 || TagTypeVideo != 9 || TagTypeScript != 18 {
+		t.Fatalf("unexpected tag type values: %d %d %d", TagTypeAudio, TagTypeVideo, TagTypeScript)
+	}
+	for _, it := range []struct {
+		tag Tag
+		s   string
+	}{
+		{Tag{Type: TagTypeAudio, Time: 23}, "audio@23ms"},
+		{Tag{Type: TagTypeVideo, Time: 40}, "video@40ms"},
+		{Tag{Type: TagTypeScript}, "script@0ms"},
+		{Tag{Type: 10, Time: 1}, "type(10)@1ms"},
+	} {
+		if s := it.tag.String(); s != it.s {
+			t.Errorf("got: %q, expected: %q", s, it.s)
+		}
+	}
+}
+
+func TestTagTimestamp(t *testing.T) {
+	tag := &Tag{Type: TagTypeVideo}
+	tag.SetTimestamp(5*time.Hour + 1500*time.Microsecond)
+	if tag.Time != 18000001 {
+		t.Fatalf("got: %d, expected: %d", tag.Time, 18000001)
+	}
+	out := &bytes.Buffer{}
+	if err := NewWriter(out).WriteTag(tag, bytes.NewReader([]byte{0x17})); err != nil {
+		t.Fatal(err)
+	}
+	if b := out.Bytes()[4:8]; !bytes.Equal(b, []byte{0x12, 0xa8, 0x81, 0x01}) {
+		t.Errorf("got timestamp bytes: %x", b)
+	}
+	for _, ms := range []int64{0xffffff, 0x1000000, 18000001, 0xffffffff} {
+		b := make([]byte, 4)
+		putTime(b, ms)
+		if v := getTime(b); v != ms {
+			t.Errorf("got: %d, expected: %d", v, ms)
+		}
+	}
+	tag.Time = 0x1000000
+	if d := tag.Timestamp(); d != 0x1000000*time.Millisecond {
+		t.Errorf("got: %v", d)
+	}
+}
+
+func TestTagHeaderRoundTrip(t *testing.T) {
+	for _, it := range []Tag{
+		{Type: TagTypeAudio, Size: 7, Time: 23},
+		{Type: TagTypeVideo, Size: 0xffffff, Time: 0x12345678, Stream: 0xabcdef},
+		{Type: TagTypeScript},
+	} {
+		b := it.MarshalHeader()
+		if len(b) != 11 {
+			t.Fatalf("got %d bytes, expected 11", len(b))
+		}
+		tag, err := UnmarshalTagHeader(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *tag != it {
+			t.Errorf("got: %+v, expected: %+v", *tag, it)
+		}
+	}
+	b := (&Tag{Type: TagTypeVideo, Size: 3, Time: 0x1234567}).MarshalHeader()
+	if expected := []byte{9, 0, 0, 3, 0x23, 0x45, 0x67, 0x01, 0, 0, 0}; !bytes.Equal(b, expected) {
+		t.Errorf("got: %x, expected: %x", b, expected)
+	}
+	if _, err := UnmarshalTagHeader(b[:10]); !errors.Is(err, ErrShortTag) {
+		t.Errorf("got: %v, expected: %v", err, ErrShortTag)
+	}
+}
+
+func TestTagMarshalJSON(t *testing.T) {
+	for _, it := range []struct {
+		tag Tag
+		s   string
+	}{
+		{Tag{Type: TagTypeAudio, Size: 7, Time: 23}, `{"type":"audio","size":7,"time":23,"stream":0}`},
+		{Tag{Type: TagTypeVideo, Size: 1234, Time: 500}, `{"type":"video","size":1234,"time":500,"stream":0}`},
+		{Tag{Type: TagTypeScript, Size: 14}, `{"type":"script","size":14,"time":0,"stream":0}`},
+		{Tag{Type: 10, Size: 1, Time: 1, Stream: 2}, `{"type":"type(10)","size":1,"time":1,"stream":2}`},
+	} {
+		b, err := json.Marshal(&it.tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != it.s {
+			t.Errorf("got: %s, expected: %s", b, it.s)
+		}
+	}
+}
+
+func TestHeaderMarshal(t *testing.T) {
+	for _, it := range []struct {
+		audio, video bool
+		flags        uint8
+	}{
+		{false, false, 0x00},
+		{false, true, 0x01},
+		{true, false, 0x04},
+		{true, true, 0x05},
+	} {
+		h := NewHeader(it.audio, it.video)
+		b := h.Marshal()
+		if expected := []byte{'F', 'L', 'V', 1, it.flags, 0, 0, 0, 9}; !bytes.Equal(b, expected) {
+			t.Errorf("got: %x, expected: %x", b, expected)
+		}
+		r := NewReader(bytes.NewReader(append(b, 0, 0, 0, 0)))
+		got, err := r.ReadHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.HasAudio() != it.audio || got.HasVideo() != it.video {
+			t.Errorf("got audio=%v video=%v, expected audio=%v video=%v", got.HasAudio(), got.HasVideo(), it.audio, it.video)
+		}
+	}
+	h := &Header{flags: FlagVideo, Extra: []byte{1, 2}}
+	if b := h.Marshal(); !bytes.Equal(b, []byte{'F', 'L', 'V', 1, 1, 0, 0, 0, 11, 1, 2}) {
+		t.Errorf("got: %x", b)
+	}
+}
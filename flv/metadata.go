@@ -0,0 +1,440 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ErrNoMetadata is returned by ReadMetadataOnly if there is no onMetaData script before the first media tag.
+var ErrNoMetadata = errors.New("flv: no metadata")
+
+var (
+	errNotMetadata = errors.New("flv: not an onMetaData script")
+	errNotScript   = errors.New("flv: not a script tag")
+)
+
+// Metadata represents the onMetaData script tag.
+// Keys missing in the stream are left zero, unknown keys are kept in Extra.
+type Metadata struct {
+	Duration        float64                `json:"duration,omitempty"`
+	Width           float64                `json:"width,omitempty"`
+	Height          float64                `json:"height,omitempty"`
+	VideoDataRate   float64                `json:"videodatarate,omitempty"`
+	AudioDataRate   float64                `json:"audiodatarate,omitempty"`
+	FrameRate       float64                `json:"framerate,omitempty"`
+	VideoCodecID    float64                `json:"videocodecid,omitempty"`
+	AudioCodecID    float64                `json:"audiocodecid,omitempty"`
+	AudioSampleRate float64                `json:"audiosamplerate,omitempty"`
+	AudioSampleSize float64                `json:"audiosamplesize,omitempty"`
+	Stereo          bool                   `json:"stereo,omitempty"`
+	FileSize        float64                `json:"filesize,omitempty"`
+	LastTimestamp   float64                `json:"lasttimestamp,omitempty"`
+	Encoder         string                 `json:"encoder,omitempty"`
+	Keyframes       []Keyframe             `json:"keyframes,omitempty"`
+	Extra           map[string]interface{} `json:"extra,omitempty"`
+}
+
+// Keyframe represents an entry of the keyframes index.
+type Keyframe struct {
+	Time     float64 `json:"time"`     // timestamp in seconds
+	Position int64   `json:"position"` // file position of the tag
+}
+
+// ParseMetadata reads the onMetaData script tag payload from r.
+func ParseMetadata(r io.Reader) (*Metadata, error) {
+	name, err := DecodeAMF0(r)
+	if err == io.EOF {
+		// Empty script tag.
+		return nil, errNotMetadata
+	}
+	if err != nil {
+		return nil, err
+	}
+	if name != "onMetaData" {
+		return nil, errNotMetadata
+	}
+	v, err := DecodeAMF0(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	props, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errNotMetadata
+	}
+	m := &Metadata{}
+	for k, v := range props {
+		if !m.set(k, v) {
+			if m.Extra == nil {
+				m.Extra = make(map[string]interface{})
+			}
+			m.Extra[k] = v
+		}
+	}
+	return m, nil
+}
+
+// SetResolutionFromSPS sets Width and Height to the dimensions decoded from the sequence parameter set NAL unit,
+// such as AVCDecoderConfig.SPS, to correct the metadata before InjectMetadata.
+// The metadata is not changed if the SPS is invalid.
+func (m *Metadata) SetResolutionFromSPS(sps []byte) error {
+	w, h, err := ParseSPSResolution(sps)
+	if err != nil {
+		return err
+	}
+	if w <= 0 || h <= 0 {
+		return fmt.Errorf("%w: %dx%d", errInvalidSPS, w, h)
+	}
+	m.Width, m.Height = float64(w), float64(h)
+	return nil
+}
+
+func (m *Metadata) set(k string, v interface{}) bool {
+	var f *float64
+	switch k {
+	case "duration":
+		f = &m.Duration
+	case "width":
+		f = &m.Width
+	case "height":
+		f = &m.Height
+	case "videodatarate":
+		f = &m.VideoDataRate
+	case "audiodatarate":
+		f = &m.AudioDataRate
+	case "framerate":
+		f = &m.FrameRate
+	case "videocodecid":
+		f = &m.VideoCodecID
+	case "audiocodecid":
+		f = &m.AudioCodecID
+	case "audiosamplerate":
+		f = &m.AudioSampleRate
+	case "audiosamplesize":
+		f = &m.AudioSampleSize
+	case "filesize":
+		f = &m.FileSize
+	case "lasttimestamp":
+		f = &m.LastTimestamp
+	case "stereo":
+		b, ok := v.(bool)
+		m.Stereo = b
+		return ok
+	case "encoder":
+		s, ok := v.(string)
+		m.Encoder = s
+		return ok
+	case "keyframes":
+		var ok bool
+		m.Keyframes, ok = parseKeyframes(v)
+		return ok
+	default:
+		return false
+	}
+	n, ok := v.(float64)
+	*f = n
+	return ok
+}
+
+func parseKeyframes(v interface{}) ([]Keyframe, bool) {
+	props, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	times, ok := props["times"].([]interface{})
+	if !ok {
+		return nil, false
+	}
+	pos, ok := props["filepositions"].([]interface{})
+	if !ok || len(pos) != len(times) {
+		return nil, false
+	}
+	r := make([]Keyframe, len(times))
+	for i := range r {
+		t, ok := times[i].(float64)
+		if !ok {
+			return nil, false
+		}
+		p, ok := pos[i].(float64)
+		if !ok {
+			return nil, false
+		}
+		r[i] = Keyframe{t, int64(p)}
+	}
+	return r, true
+}
+
+// KeyframeIndex reads the header unless it is already read and the keyframes index from onMetaData script tag
+// following it. It returns an empty index if the next tag is not a script tag, leaving it unread.
+func (r *Reader) KeyframeIndex() ([]Keyframe, error) {
+	tag, err := r.peekTag()
+	if err != nil {
+		return nil, err
+	}
+	if tag.Type != TagTypeScript {
+		return []Keyframe{}, nil
+	}
+	_, data, err := r.ReadTag()
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseMetadata(data)
+	if err == errNotMetadata {
+		return []Keyframe{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.Keyframes == nil {
+		return []Keyframe{}, nil
+	}
+	return m.Keyframes, nil
+}
+
+// SeekToKeyframe positions the reader at the last video keyframe with timestamp at or before ms milliseconds,
+// or the first keyframe if there is none, and returns its timestamp. The keyframe is returned by the following ReadTag.
+// It uses the keyframes index of onMetaData if present and valid, otherwise tags are scanned from the first one.
+// It returns io.EOF if there are no keyframes.
+// The underlying reader must implement io.Seeker. The header is read first unless it is already read.
+func (r *Reader) SeekToKeyframe(ms int64) (int64, error) {
+	if r.s == nil {
+		return 0, errNotSeekable
+	}
+	if r.data == 0 {
+		if _, err := r.ReadHeader(); err != nil {
+			return 0, err
+		}
+	}
+	if err := r.seek(r.data); err != nil {
+		return 0, err
+	}
+	r.prev = 0
+	index, err := r.KeyframeIndex()
+	if err != nil {
+		return 0, err
+	}
+	if len(index) > 0 {
+		k := index[0]
+		for _, it := range index[1:] {
+			if it.Time <= float64(ms)/1000 {
+				k = it
+			}
+		}
+		// Positions point to the tag header following PreviousTagSize.
+		t, ok, err := r.seekKeyframe(k.Position - 4)
+		if err != nil || ok {
+			return t, err
+		}
+	}
+	return r.scanKeyframe(ms)
+}
+
+// ByteRangeForTime returns the size of the header with the first PreviousTagSize field and
+// the offset of the keyframe tag found by SeekToKeyframe for ms milliseconds.
+// A pseudo-streaming server responds with the first headerLen bytes of the stream followed by
+// the stream from startOffset. The reader is left positioned at the keyframe.
+func (r *Reader) ByteRangeForTime(ms int64) (headerLen int, startOffset int64, err error) {
+	if _, err = r.SeekToKeyframe(ms); err != nil {
+		return 0, 0, err
+	}
+	return int(r.data) + 4, r.Offset() + 4, nil
+}
+
+// isKeyframe reports whether b holding PreviousTagSize, tag header and the first two bytes of payload
+// is a video keyframe other than a sequence header.
+func isKeyframe(b []byte, tag *Tag) bool {
+	p := b[15:min(len(b), 15+tag.Size)]
+	return tag.Type == TagTypeVideo && len(p) > 0 && p[0]>>4&7 == FrameTypeKey && sequenceHeader(tag, p) < 0
+}
+
+// seekKeyframe positions the reader at off and reports whether there is a keyframe.
+func (r *Reader) seekKeyframe(off int64) (int64, bool, error) {
+	if off < r.data {
+		return 0, false, nil
+	}
+	if err := r.seek(off); err != nil {
+		return 0, false, err
+	}
+	r.prev = -1
+	b, err := r.next(17)
+	if err != nil {
+		return 0, false, nil
+	}
+	r.unread()
+	tag := parseTag(b)
+	return tag.Time, isKeyframe(b, tag), nil
+}
+
+func (r *Reader) scanKeyframe(ms int64) (int64, error) {
+	if err := r.seek(r.data); err != nil {
+		return 0, err
+	}
+	r.prev = 0
+	found := false
+	var off, prev, t int64
+	for {
+		b, err := r.next(17)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		tag := parseTag(b)
+		if isKeyframe(b, tag) {
+			if tag.Time > ms && found {
+				break
+			}
+			found, off, prev, t = true, r.off, r.prev, tag.Time
+			if tag.Time > ms {
+				break
+			}
+		}
+		r.unread()
+		r.skip(15 + tag.Size)
+		r.prev = int64(tag.Size) + 11
+	}
+	if !found {
+		return 0, io.EOF
+	}
+	if err := r.seek(off); err != nil {
+		return 0, err
+	}
+	r.prev = prev
+	return t, nil
+}
+
+// ReadMetadataOnly reads the header unless it is already read and script tags following it
+// until onMetaData is found. It does not read audio and video tags, the first of them
+// is left unread and ErrNoMetadata is returned.
+func (r *Reader) ReadMetadataOnly() (*Metadata, error) {
+	for {
+		tag, err := r.peekTag()
+		if err == io.EOF {
+			return nil, ErrNoMetadata
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tag.Type != TagTypeScript {
+			return nil, ErrNoMetadata
+		}
+		_, data, err := r.ReadTag()
+		if err != nil {
+			return nil, err
+		}
+		m, err := ParseMetadata(data)
+		if err != errNotMetadata {
+			return m, err
+		}
+	}
+}
+
+// ReadScriptData reads the header unless it is already read and the next tag, which must be a script tag,
+// and decodes its AMF0 command name and the following value, such as onMetaData, onCuePoint or onTextData
+// and its parameters.
+// The value is nil if the tag contains the name only, both are empty if the tag has no payload.
+// If the next tag is not a script tag, it is left unread.
+func (r *Reader) ReadScriptData() (name string, value interface{}, err error) {
+	tag, err := r.peekTag()
+	if err != nil {
+		return "", nil, err
+	}
+	if tag.Type != TagTypeScript {
+		return "", nil, fmt.Errorf("%w: %s", errNotScript, tag)
+	}
+	_, data, err := r.ReadTag()
+	if err != nil {
+		return "", nil, err
+	}
+	return decodeScriptData(data)
+}
+
+// decodeScriptData decodes the command name and the value of script tag payload.
+func decodeScriptData(data io.Reader) (name string, value interface{}, err error) {
+	v, err := DecodeAMF0(data)
+	if err == io.EOF {
+		// Empty script tag.
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	name, ok := v.(string)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: name %T", errNotScript, v)
+	}
+	if value, err = DecodeAMF0(data); err == io.EOF {
+		err = nil
+	}
+	return name, value, err
+}
+
+// WriteMetadata writes onMetaData script tag at timestamp 0.
+func (w *Writer) WriteMetadata(m *Metadata) error {
+	b, err := m.encode()
+	if err != nil {
+		return err
+	}
+	return w.WriteTag(&Tag{Type: TagTypeScript, Size: len(b)}, bytes.NewReader(b))
+}
+
+func (m *Metadata) encode() ([]byte, error) {
+	e := &amf0Encoder{}
+	e.writeString("onMetaData")
+	if err := e.writeECMAArray(m.properties()); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func (m *Metadata) properties() []amf0Property {
+	props := []amf0Property{
+		{"duration", m.Duration},
+		{"width", m.Width},
+		{"height", m.Height},
+		{"videodatarate", m.VideoDataRate},
+		{"framerate", m.FrameRate},
+		{"audiodatarate", m.AudioDataRate},
+	}
+	for _, it := range []amf0Property{
+		{"videocodecid", m.VideoCodecID},
+		{"audiocodecid", m.AudioCodecID},
+		{"audiosamplerate", m.AudioSampleRate},
+		{"audiosamplesize", m.AudioSampleSize},
+		{"filesize", m.FileSize},
+		{"lasttimestamp", m.LastTimestamp},
+	} {
+		if it.value != 0.0 {
+			props = append(props, it)
+		}
+	}
+	if m.Stereo {
+		props = append(props, amf0Property{"stereo", true})
+	}
+	if m.Encoder != "" {
+		props = append(props, amf0Property{"encoder", m.Encoder})
+	}
+	if m.Keyframes != nil {
+		times := make([]interface{}, len(m.Keyframes))
+		pos := make([]interface{}, len(m.Keyframes))
+		for i, it := range m.Keyframes {
+			times[i], pos[i] = it.Time, float64(it.Position)
+		}
+		props = append(props, amf0Property{"keyframes", []amf0Property{
+			{"times", times},
+			{"filepositions", pos},
+		}})
+	}
+	keys := make([]string, 0, len(m.Extra))
+	for k := range m.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		props = append(props, amf0Property{k, m.Extra[k]})
+	}
+	return props
+}
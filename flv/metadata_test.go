@@ -0,0 +1,436 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	m, err := ParseMetadata(bytes.NewReader(metaDataPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Metadata{
+		Duration:        10.01,
+		Width:           1280,
+		Height:          720,
+		VideoDataRate:   1953.125,
+		AudioDataRate:   125,
+		FrameRate:       30,
+		VideoCodecID:    7,
+		AudioCodecID:    10,
+		AudioSampleRate: 44100,
+		AudioSampleSize: 16,
+		Stereo:          true,
+		FileSize:        2672346,
+		Encoder:         "Lavf58.76.100",
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("got: %#v, expected: %#v", m, expected)
+	}
+}
+
+func TestParseMetadataPartial(t *testing.T) {
+	b := []byte{
+		0x02, 0x00, 0x0a, 'o', 'n', 'M', 'e', 't', 'a', 'D', 'a', 't', 'a',
+		0x08, 0x00, 0x00, 0x00, 0x02,
+		0x00, 0x05, 'w', 'i', 'd', 't', 'h', 0x00, 0x40, 0x84, 0, 0, 0, 0, 0, 0,
+		0x00, 0x06, 'c', 'u', 's', 't', 'o', 'm', 0x02, 0x00, 0x01, 'x',
+		0x00, 0x00, 0x09,
+	}
+	m, err := ParseMetadata(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Metadata{Width: 640, Extra: map[string]interface{}{"custom": "x"}}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("got: %#v, expected: %#v", m, expected)
+	}
+	if _, err = ParseMetadata(bytes.NewReader([]byte{0x02, 0x00, 0x01, 'x', 0x05})); err != errNotMetadata {
+		t.Errorf("got: %v, expected: %v", err, errNotMetadata)
+	}
+}
+
+func amfString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+func amfNumber(v float64) []byte {
+	b := make([]byte, 9)
+	u := math.Float64bits(v)
+	putUint32(b[1:], uint32(u>>32))
+	putUint32(b[5:], uint32(u))
+	return b
+}
+
+func amfStrictArray(v ...float64) []byte {
+	b := make([]byte, 5)
+	b[0] = 0x0a
+	putUint32(b[1:], uint32(len(v)))
+	for _, it := range v {
+		b = append(b, amfNumber(it)...)
+	}
+	return b
+}
+
+func TestKeyframeIndex(t *testing.T) {
+	var times, pos []float64
+	for i := 0; i < 10; i++ {
+		times = append(times, float64(i)*2)
+		pos = append(pos, float64(1000+i*5000))
+	}
+	b := append([]byte{0x02}, amfString("onMetaData")...)
+	b = append(b, 0x08, 0, 0, 0, 2)
+	b = append(append(b, amfString("duration")...), amfNumber(20)...)
+	b = append(append(b, amfString("keyframes")...), 0x03)
+	b = append(append(b, amfString("times")...), amfStrictArray(times...)...)
+	b = append(append(b, amfString("filepositions")...), amfStrictArray(pos...)...)
+	b = append(b, 0, 0, 0x09, 0, 0, 0x09)
+
+	in := buildFLV(1, testTag{TagTypeScript, 0, b}, testTag{TagTypeVideo, 0, []byte{0x17, 1, 0, 0, 0}})
+	var r *Reader
+	for _, header := range []bool{false, true} {
+		// The header is read by KeyframeIndex unless it is already read.
+		r = NewReader(bytes.NewReader(in))
+		if header {
+			if _, err := r.ReadHeader(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		index, err := r.KeyframeIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(index) != 10 {
+			t.Fatalf("got %d keyframes, expected 10", len(index))
+		}
+		for i, it := range index {
+			if it.Time != times[i] || it.Position != int64(pos[i]) {
+				t.Errorf("got: %#v, expected: %v at %v", it, times[i], pos[i])
+			}
+		}
+	}
+	index, err := r.KeyframeIndex()
+	if err != nil || index == nil || len(index) != 0 {
+		t.Errorf("got: %#v, %v, expected empty index", index, err)
+	}
+	if tag, _, err := r.ReadTag(); err != nil || tag.Type != TagTypeVideo {
+		t.Errorf("got: %v, %v, expected video tag", tag, err)
+	}
+}
+
+func TestWriteMetadata(t *testing.T) {
+	m := &Metadata{
+		Duration:      12.5,
+		Width:         1920,
+		Height:        1080,
+		VideoDataRate: 4500,
+		AudioDataRate: 128,
+		FrameRate:     29.97,
+		VideoCodecID:  7,
+		Stereo:        true,
+		Encoder:       "go-flv",
+		Keyframes:     []Keyframe{{0, 400}, {2, 90000}},
+		Extra:         map[string]interface{}{"custom": "value", "list": []interface{}{1.0, "a"}},
+	}
+	out := &bytes.Buffer{}
+	w := NewWriter(out)
+	if err := w.WriteHeader(NewHeader(false, true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteMetadata(m); err != nil {
+		t.Fatal(err)
+	}
+	b := out.Bytes()
+	if end := b[len(b)-7 : len(b)-4]; !bytes.Equal(end, []byte{0, 0, 9}) {
+		t.Errorf("got end marker: %x", end)
+	}
+	r := NewReader(out)
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	tag, data, err := r.ReadTag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Type != TagTypeScript || tag.Time != 0 {
+		t.Errorf("got: %v, expected script tag at 0", tag)
+	}
+	got, err := ParseMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("got: %#v, expected: %#v", got, m)
+	}
+}
+
+func TestReadMetadataOnly(t *testing.T) {
+	cue := testTag{TagTypeScript, 0, append([]byte{0x02}, amfString("onCuePoint")...)}
+	meta := testTag{TagTypeScript, 0, metaDataPayload}
+	r := NewReader(bytes.NewReader(buildFLV(5, cue, meta, testTags[1])))
+	m, err := r.ReadMetadataOnly()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 1280 || m.Height != 720 || m.Duration != 10.01 {
+		t.Errorf("got: %#v", m)
+	}
+
+	r = NewReader(bytes.NewReader(buildFLV(5, testTags[1], meta)))
+	if _, err = r.ReadMetadataOnly(); err != ErrNoMetadata {
+		t.Errorf("got: %v, expected: %v", err, ErrNoMetadata)
+	}
+	if tag, _, err := r.ReadTag(); err != nil || tag.Type != TagTypeVideo {
+		t.Errorf("got: %v, %v, expected video tag", tag, err)
+	}
+	if _, err = NewReader(bytes.NewReader(buildFLV(5, cue))).ReadMetadataOnly(); err != ErrNoMetadata {
+		t.Errorf("got: %v, expected: %v", err, ErrNoMetadata)
+	}
+}
+
+func BenchmarkReadMetadataOnly(b *testing.B) {
+	in := append(buildFLV(5, testTag{TagTypeScript, 0, metaDataPayload}), benchmarkFLV()[13:]...)
+	for i := 0; i < b.N; i++ {
+		if _, err := NewReader(bytes.NewReader(in)).ReadMetadataOnly(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReadScriptData(t *testing.T) {
+	var cue []byte
+	cue = append(cue, 0x02)
+	cue = append(cue, amfString("onCuePoint")...)
+	cue = append(cue, 0x03)
+	cue = append(cue, amfString("name")...)
+	cue = append(cue, 0x02)
+	cue = append(cue, amfString("ad1")...)
+	cue = append(cue, amfString("time")...)
+	cue = append(cue, amfNumber(12.5)...)
+	cue = append(cue, amfString("parameters")...)
+	cue = append(cue, 0x03)
+	cue = append(cue, amfString("id")...)
+	cue = append(cue, 0x02)
+	cue = append(cue, amfString("x")...)
+	cue = append(cue, 0x00, 0x00, 0x09, 0x00, 0x00, 0x09)
+	// The header is read by the first call.
+	r := NewReader(bytes.NewReader(buildFLV(5, testTags[0], testTag{TagTypeScript, 12500, cue}, testTags[1])))
+	for _, it := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"onMetaData", nil},
+		{"onCuePoint", map[string]interface{}{
+			"name":       "ad1",
+			"time":       12.5,
+			"parameters": map[string]interface{}{"id": "x"},
+		}},
+	} {
+		name, v, err := r.ReadScriptData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != it.name || !reflect.DeepEqual(v, it.value) {
+			t.Errorf("got: %s %#v, expected: %s %#v", name, v, it.name, it.value)
+		}
+	}
+	if _, _, err := r.ReadScriptData(); !errors.Is(err, errNotScript) {
+		t.Errorf("got: %v, expected: %v", err, errNotScript)
+	}
+	if tag, _, err := r.ReadTag(); err != nil || tag.Type != TagTypeVideo {
+		t.Errorf("got: %v, %v, expected video tag", tag, err)
+	}
+}
+
+// keyframeFLV returns a stream with a keyframe every second and onMetaData with keyframes index
+// shifted by the given number of bytes, if index is set.
+func keyframeFLV(index bool, shift int64) []byte {
+	var tags []testTag
+	for ms := int64(0); ms < 4000; ms += 40 {
+		v := []byte{0x27, 1, 0, 0, 0, byte(ms / 40)}
+		if ms%1000 == 0 {
+			v[0] = 0x17
+		}
+		tags = append(tags, testTag{TagTypeVideo, ms, v}, testTag{TagTypeAudio, ms + 10, []byte{0xaf, 1, 0}})
+	}
+	if !index {
+		return buildFLV(5, tags...)
+	}
+	m := &Metadata{Duration: 4, Keyframes: make([]Keyframe, 4)}
+	b, _ := m.encode()
+	pos := int64(13 + 11 + len(b) + 4)
+	for _, it := range tags {
+		if it.typ == TagTypeVideo && it.data[0] == 0x17 {
+			m.Keyframes[it.time/1000] = Keyframe{float64(it.time) / 1000, pos + shift}
+		}
+		pos += int64(len(it.data)) + 15
+	}
+	b, _ = m.encode()
+	return buildFLV(5, append([]testTag{{TagTypeScript, 0, b}}, tags...)...)
+}
+
+func TestReaderSeekToKeyframe(t *testing.T) {
+	for _, in := range [][]byte{keyframeFLV(true, 0), keyframeFLV(true, 1), keyframeFLV(false, 0)} {
+		// The header is read by the first SeekToKeyframe.
+		r := NewReader(bytes.NewReader(in))
+		r.Strict = true
+		for _, it := range []struct{ ms, time int64 }{{2500, 2000}, {0, 0}, {999, 0}, {3000, 3000}, {10000, 3000}} {
+			ms, err := r.SeekToKeyframe(it.ms)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ms != it.time {
+				t.Errorf("seek to %d: got: %d, expected: %d", it.ms, ms, it.time)
+			}
+			tag, data, err := r.ReadTag()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b, _ := io.ReadAll(data); tag.Type != TagTypeVideo || tag.Time != it.time || b[0] != 0x17 {
+				t.Errorf("seek to %d: got: %s %x", it.ms, tag, b)
+			}
+			if _, _, err = r.ReadTag(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// The sequence header is not a keyframe.
+	r := NewReader(bytes.NewReader(buildFLV(1,
+		testTag{TagTypeVideo, 0, []byte{0x17, 0, 0, 0, 0, 1}},
+		testTag{TagTypeVideo, 40, []byte{0x17, 1, 0, 0, 0, 1}},
+	)))
+	r.ReadHeader()
+	if ms, err := r.SeekToKeyframe(0); err != nil || ms != 40 {
+		t.Errorf("got: %d, %v, expected keyframe at 40", ms, err)
+	}
+	r = NewReader(bytes.NewReader(buildFLV(5, testTags[2:4]...)))
+	r.ReadHeader()
+	if _, err := r.SeekToKeyframe(0); err != io.EOF {
+		t.Errorf("got: %v, expected: %v", err, io.EOF)
+	}
+	r = NewReader(bytes.NewBuffer(keyframeFLV(false, 0)))
+	r.ReadHeader()
+	if _, err := r.SeekToKeyframe(0); err != errNotSeekable {
+		t.Errorf("got: %v, expected: %v", err, errNotSeekable)
+	}
+}
+
+func TestReaderByteRangeForTime(t *testing.T) {
+	for _, in := range [][]byte{keyframeFLV(true, 0), keyframeFLV(false, 0)} {
+		r := NewReader(bytes.NewReader(in))
+		if _, err := r.ReadHeader(); err != nil {
+			t.Fatal(err)
+		}
+		n, off, err := r.ByteRangeForTime(1500)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 13 {
+			t.Errorf("got header length %d, expected 13", n)
+		}
+		if in[off] != TagTypeVideo || getTime(in[off+4:]) != 1000 || in[off+11] != 0x17 {
+			t.Errorf("got offset %d: %x", off, in[off:off+12])
+		}
+		// The response is a valid stream starting at the keyframe.
+		resp := NewReader(bytes.NewReader(append(append([]byte{}, in[:n]...), in[off:]...)))
+		resp.Strict = true
+		if _, err = resp.ReadHeader(); err != nil {
+			t.Fatal(err)
+		}
+		if tag, _, err := resp.ReadTag(); err != nil || tag.Time != 1000 {
+			t.Errorf("got: %v, %v", tag, err)
+		}
+	}
+}
+
+func TestReaderEmptyScriptTag(t *testing.T) {
+	empty := testTag{TagTypeScript, 0, nil}
+	in := buildFLV(5, empty, testTag{TagTypeScript, 0, metaDataPayload}, empty, testTags[1])
+	r := NewReader(bytes.NewReader(in))
+	r.Strict = true
+	tag, data, err := r.ReadTag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(data); tag.Size != 0 || len(b) != 0 || err != nil {
+		t.Errorf("got: %v %x %v, expected empty payload", tag, b, err)
+	}
+	r = NewReader(bytes.NewReader(in))
+	m, err := r.ReadMetadataOnly()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 1280 {
+		t.Errorf("got: %+v", m)
+	}
+	name, v, err := r.ReadScriptData()
+	if name != "" || v != nil || err != nil {
+		t.Errorf("got: %q %v %v, expected empty script data", name, v, err)
+	}
+	if tag, _, err = r.ReadTag(); err != nil || tag.Type != TagTypeVideo {
+		t.Errorf("got: %v %v, expected video tag", tag, err)
+	}
+	if err = NewReader(bytes.NewReader(in)).Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReaderOnScript(t *testing.T) {
+	last := append([]byte{0x02}, amfString("onLastSecond")...)
+	last = append(last, 0x03)
+	last = append(last, amfString("time")...)
+	last = append(last, amfNumber(2)...)
+	last = append(last, 0x00, 0x00, 0x09)
+	tags := append(testTags[:len(testTags):len(testTags)], testTag{TagTypeScript, 1000, last}, testTag{TagTypeScript, 2000, nil})
+	r := NewReader(bytes.NewReader(buildFLV(5, tags...)))
+	var names []string
+	var values []interface{}
+	r.OnScript = func(name string, value interface{}) {
+		names = append(names, name)
+		values = append(values, value)
+	}
+	i := 0
+	if err := r.Walk(func(tag *Tag, data io.Reader) error {
+		b, err := io.ReadAll(data)
+		if err != nil {
+			return err
+		}
+		if tag.Type != tags[i].typ || !bytes.Equal(b, tags[i].data) {
+			t.Errorf("tag %d: got: %v %x, expected: %x", i, tag, b, tags[i].data)
+		}
+		i++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if i != len(tags) {
+		t.Errorf("got %d tags, expected %d", i, len(tags))
+	}
+	if !reflect.DeepEqual(names, []string{"onMetaData", "onLastSecond"}) {
+		t.Errorf("got names: %v", names)
+	}
+	if !reflect.DeepEqual(values, []interface{}{nil, map[string]interface{}{"time": 2.0}}) {
+		t.Errorf("got values: %#v", values)
+	}
+}
+
+func TestMetadataSetResolutionFromSPS(t *testing.T) {
+	m := &Metadata{Width: 640, Height: 480}
+	if err := m.SetResolutionFromSPS(testSPS); err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 1280 || m.Height != 720 {
+		t.Errorf("got: %vx%v, expected: 1280x720", m.Width, m.Height)
+	}
+	if err := m.SetResolutionFromSPS(testPPS); !errors.Is(err, errInvalidSPS) {
+		t.Errorf("got: %v, expected: %v", err, errInvalidSPS)
+	}
+	if m.Width != 1280 || m.Height != 720 {
+		t.Errorf("metadata is changed: %vx%v", m.Width, m.Height)
+	}
+}
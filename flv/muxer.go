@@ -0,0 +1,67 @@
+package flv
+
+import "bytes"
+
+// Muxer writes AVC video and AAC audio frames as FLV tags.
+type Muxer struct {
+	w   *Writer
+	buf []byte
+	r   bytes.Reader
+}
+
+// NewMuxer returns a new muxer writing tags to w.
+// The FLV header and metadata, if any, should be written by w beforehand.
+func NewMuxer(w *Writer) *Muxer {
+	return &Muxer{w: w}
+}
+
+// WriteVideoSequenceHeader writes AVC sequence header tag carrying AVCDecoderConfigurationRecord.
+// It must precede the video frames and may be repeated if the configuration changes.
+func (m *Muxer) WriteVideoSequenceHeader(dts int64, config []byte) error {
+	return m.writeVideo(dts, 0, FrameTypeKey, AVCPacketTypeSequenceHeader, config)
+}
+
+// WriteVideoAVC writes AVC video tag with length-prefixed NALUs of a single frame.
+// The dts is the decoding timestamp and cts is the composition time offset in milliseconds,
+// that is the difference between the presentation and decoding timestamps.
+func (m *Muxer) WriteVideoAVC(dts, cts int64, keyframe bool, naluData []byte) error {
+	t := FrameTypeInter
+	if keyframe {
+		t = FrameTypeKey
+	}
+	return m.writeVideo(dts, cts, t, AVCPacketTypeNALU, naluData)
+}
+
+// WriteVideoEndOfSequence writes AVC end of sequence tag, which should be the last video tag of the stream.
+func (m *Muxer) WriteVideoEndOfSequence(dts int64) error {
+	return m.writeVideo(dts, 0, FrameTypeKey, AVCPacketTypeEndOfSequence, nil)
+}
+
+// WriteAudioSequenceHeader writes AAC sequence header tag carrying AudioSpecificConfig.
+// It must precede the audio frames.
+func (m *Muxer) WriteAudioSequenceHeader(dts int64, config []byte) error {
+	return m.writeAudio(dts, AACPacketTypeSequenceHeader, config)
+}
+
+// WriteAudioAAC writes AAC audio tag with a raw AAC frame without ADTS header.
+func (m *Muxer) WriteAudioAAC(dts int64, frame []byte) error {
+	return m.writeAudio(dts, AACPacketTypeRaw, frame)
+}
+
+func (m *Muxer) writeVideo(dts, cts int64, frameType, packetType byte, data []byte) error {
+	m.buf = append(m.buf[:0], frameType<<4|CodecIDAVC, packetType, 0, 0, 0)
+	putUint24(m.buf[2:], uint32(cts))
+	return m.write(TagTypeVideo, dts, data)
+}
+
+func (m *Muxer) writeAudio(dts int64, packetType byte, data []byte) error {
+	// AAC is always signaled as 44 kHz 16-bit stereo, the actual parameters are in AudioSpecificConfig.
+	m.buf = append(m.buf[:0], byte(SoundFormatAAC)<<4|0x0f, packetType)
+	return m.write(TagTypeAudio, dts, data)
+}
+
+func (m *Muxer) write(typ uint8, dts int64, data []byte) error {
+	m.buf = append(m.buf, data...)
+	m.r.Reset(m.buf)
+	return m.w.WriteTag(&Tag{Type: typ, Size: len(m.buf), Time: dts}, &m.r)
+}
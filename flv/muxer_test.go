@@ -0,0 +1,112 @@
+package flv
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestMuxer(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := NewWriter(out)
+	if err := w.WriteHeader(NewHeader(true, true)); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMuxer(w)
+	asc := []byte{0x12, 0x10}
+	frames := []struct {
+		typ      uint8
+		dts, cts int64
+		key      bool
+		data     []byte
+	}{
+		{TagTypeVideo, 0, 80, true, []byte{0, 0, 0, 2, 0x65, 0x88}},
+		{TagTypeAudio, 0, 0, false, []byte{0x21, 0x10}},
+		{TagTypeVideo, 40, -40, false, []byte{0, 0, 0, 1, 0x41}},
+		{TagTypeAudio, 23, 0, false, []byte{0x21, 0x11, 0x12}},
+		{TagTypeVideo, 80, 0, false, []byte{0, 0, 0, 1, 0x01}},
+	}
+	if err := m.WriteVideoSequenceHeader(0, testAVCConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteAudioSequenceHeader(0, asc); err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range frames {
+		var err error
+		if it.typ == TagTypeVideo {
+			err = m.WriteVideoAVC(it.dts, it.cts, it.key, it.data)
+		} else {
+			err = m.WriteAudioAAC(it.dts, it.data)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.WriteVideoEndOfSequence(120); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(bytes.NewReader(out.Bytes()))
+	r.Strict = true
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	tag, data, err := r.ReadTag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, data, err := ParseVideoHeader(data)
+	if err != nil || v.AVCPacketType != AVCPacketTypeSequenceHeader {
+		t.Fatalf("got: %#v, %v", v, err)
+	}
+	if c, err := ParseAVCDecoderConfig(data); err != nil || c.NALULengthSize != 4 {
+		t.Errorf("got: %#v, %v", c, err)
+	}
+	tag, data, err = r.ReadTag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, data, err := ParseAudioHeader(data)
+	if err != nil || a.Format != SoundFormatAAC || a.AACPacketType != AACPacketTypeSequenceHeader {
+		t.Fatalf("got: %#v, %v", a, err)
+	}
+	if b, _ := io.ReadAll(data); !bytes.Equal(b, asc) {
+		t.Errorf("got config: %x, expected: %x", b, asc)
+	}
+	for i, it := range frames {
+		if tag, data, err = r.ReadTag(); err != nil {
+			t.Fatal(err)
+		}
+		if tag.Type != it.typ || tag.Time != it.dts {
+			t.Errorf("frame %d: got %s, expected %s", i, tag, &Tag{Type: it.typ, Time: it.dts})
+		}
+		if it.typ == TagTypeVideo {
+			if v, data, err = ParseVideoHeader(data); err != nil {
+				t.Fatal(err)
+			}
+			if v.AVCPacketType != AVCPacketTypeNALU || int64(v.CompositionTime) != it.cts || v.IsKeyframe() != it.key {
+				t.Errorf("frame %d: got: %#v", i, v)
+			}
+		} else {
+			if a, data, err = ParseAudioHeader(data); err != nil {
+				t.Fatal(err)
+			}
+			if a.AACPacketType != AACPacketTypeRaw {
+				t.Errorf("frame %d: got: %#v", i, a)
+			}
+		}
+		if b, _ := io.ReadAll(data); !bytes.Equal(b, it.data) {
+			t.Errorf("frame %d: got: %x, expected: %x", i, b, it.data)
+		}
+	}
+	if tag, data, err = r.ReadTag(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, err = ParseVideoHeader(data); err != nil || !v.IsEndOfSequence() || tag.Time != 120 {
+		t.Errorf("got: %s %#v, %v", tag, v, err)
+	}
+	if _, _, err = r.ReadTag(); err != io.EOF {
+		t.Errorf("got: %v, expected: %v", err, io.EOF)
+	}
+}
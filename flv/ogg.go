@@ -0,0 +1,137 @@
+package flv
+
+import (
+	"errors"
+	"io"
+)
+
+var errOggPacketSize = errors.New("flv: packet is too large for ogg page")
+
+var oggCRCTable = func() (t [256]uint32) {
+	for i := range t {
+		c := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04c11db7
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return
+}()
+
+func oggCRC(b []byte) uint32 {
+	var c uint32
+	for _, v := range b {
+		c = c<<8 ^ oggCRCTable[byte(c>>24)^v]
+	}
+	return c
+}
+
+// Header type flags of Ogg page.
+const (
+	oggBOS = 0x02 // beginning of stream
+	oggEOS = 0x04 // end of stream
+)
+
+// oggWriter writes a logical Ogg bitstream with a single packet per page.
+type oggWriter struct {
+	w      io.Writer
+	serial uint32
+	seq    uint32
+	buf    []byte
+}
+
+func (w *oggWriter) writePacket(p []byte, granule int64, flags byte) error {
+	n := len(p)/255 + 1
+	if n > 255 {
+		return errOggPacketSize
+	}
+	b := w.buf[:0]
+	b = append(b, 'O', 'g', 'g', 'S', 0, flags)
+	for i := 0; i < 8; i++ {
+		b = append(b, byte(granule>>(8*i)))
+	}
+	b = appendUint32LE(b, w.serial)
+	b = appendUint32LE(b, w.seq)
+	b = append(b, 0, 0, 0, 0, byte(n))
+	for i := 1; i < n; i++ {
+		b = append(b, 255)
+	}
+	b = append(b, byte(len(p)%255))
+	b = append(b, p...)
+	crc := oggCRC(b)
+	b[22], b[23], b[24], b[25] = byte(crc), byte(crc>>8), byte(crc>>16), byte(crc>>24)
+	w.buf = b
+	w.seq++
+	_, err := w.w.Write(b)
+	return err
+}
+
+func appendUint32LE(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// speexWriter writes Speex frames of FLV audio tags as Ogg Speex stream.
+// FLV Speex audio is always 16 kHz mono wideband with 20 ms frames.
+type speexWriter struct {
+	ogg     oggWriter
+	pending []byte // the last frame, which is written on the next one or on close
+	granule int64  // granule position of the pending frame
+}
+
+func newSpeexWriter(w io.Writer) (*speexWriter, error) {
+	s := &speexWriter{ogg: oggWriter{w: w, serial: 1}}
+	h := make([]byte, 0, 80)
+	h = append(h, "Speex   1.2"...)
+	h = append(h, make([]byte, 17)...)
+	for _, v := range []int32{
+		1,     // speex_version_id
+		80,    // header_size
+		16000, // rate
+		1,     // mode, wideband
+		4,     // mode_bitstream_version
+		1,     // nb_channels
+		-1,    // bitrate
+		320,   // frame_size
+		0,     // vbr
+		1,     // frames_per_packet
+		0,     // extra_headers
+		0,     // reserved1
+		0,     // reserved2
+	} {
+		h = appendUint32LE(h, uint32(v))
+	}
+	if err := s.ogg.writePacket(h, 0, oggBOS); err != nil {
+		return nil, err
+	}
+	vendor := "go-flv"
+	c := appendUint32LE(nil, uint32(len(vendor)))
+	c = append(c, vendor...)
+	c = appendUint32LE(c, 0)
+	if err := s.ogg.writePacket(c, 0, 0); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *speexWriter) writeFrame(ms int64, p []byte) error {
+	if s.pending != nil {
+		if err := s.ogg.writePacket(s.pending, s.granule, 0); err != nil {
+			return err
+		}
+	}
+	s.pending = append(s.pending[:0], p...)
+	// Granule position is the number of samples at the end of the frame.
+	s.granule = (ms + 20) * 16
+	return nil
+}
+
+func (s *speexWriter) close() error {
+	if s.pending == nil {
+		return nil
+	}
+	return s.ogg.writePacket(s.pending, s.granule, oggEOS)
+}
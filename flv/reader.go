@@ -2,19 +2,141 @@ package flv
 
 import (
 	"bufio"
-	"encoding/hex"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"sync"
+	"time"
 )
 
+// Errors returned by Reader.
+var (
+	ErrBadSignature       = errors.New("flv: incorrect signature")
+	ErrUnsupportedVersion = errors.New("flv: unsupported version")
+	ErrShortTag           = errors.New("flv: short tag")
+	ErrTagTooLarge        = errors.New("flv: tag too large")
+	ErrBadDataOffset      = errors.New("flv: invalid header data offset")
+	ErrNoSignature        = errors.New("flv: signature not found")
+	ErrEncrypted          = errors.New("flv: encrypted tag")
+	ErrScanLimitExceeded  = errors.New("flv: scan limit exceeded")
+)
+
+var (
+	errNotSeekable    = errors.New("flv: reader is not seekable")
+	errUnknownTagType = errors.New("flv: unknown tag type")
+	errTimestampOrder = errors.New("flv: timestamp goes backward")
+	errNoTagBoundary  = errors.New("flv: no tag boundary found")
+	errTrackFlags     = errors.New("flv: tag type contradicts header flags")
+	errStreamID       = errors.New("flv: nonzero stream id")
+)
+
+// maxResyncScan is the number of bytes Resync scans before giving up.
+const maxResyncScan = 1 << 20
+
 // Reader reads FLV header and tags from an input stream.
 type Reader struct {
 	*fileReader
+
+	// Strict enables validation of PreviousTagSize fields, including presence of the final one,
+	// of tag types, which must be known and match the header flags, and of stream IDs,
+	// which must be zero by the specification.
+	Strict bool
+
+	// MaxTagSize limits the tag payload size, ReadTag returns ErrTagTooLarge for larger tags.
+	// Zero means no limit other than 16 MB of 24-bit size field.
+	MaxTagSize int
+
+	// MultiHeader enables reading of concatenated streams, where a new FLV header
+	// may appear in place of a tag, for example after reconnection of a live stream.
+	MultiHeader bool
+
+	// BufferPool, if set, provides payload buffers for ReadTagBytes.
+	// It must hold *[]byte values, buffers too small for a payload are dropped.
+	BufferPool *sync.Pool
+
+	// UnwrapTimestamps enables detection of timestamp wraparounds of long live recordings, either of the 24-bit
+	// base field whose extended byte is not written by some encoders, or of the whole 32-bit timestamp.
+	// A backward jump from within 10 seconds before the wrap period to within 10 seconds after zero
+	// increases Tag.AbsoluteTime of the following tags by the period, other backward jumps are kept.
+	UnwrapTimestamps bool
+
+	// CustomTagTypes are tag types accepted in addition to audio, video and script types.
+	// Tags of other types are unknown, they are returned unless the reader is strict.
+	CustomTagTypes []uint8
+
+	// OnUnknownTag, if set, is called with the header of every tag of unknown type before it is returned.
+	OnUnknownTag func(t *Tag)
+
+	// MaxScanBytes limits the number of bytes scanned by Validate and Duration, which read the whole stream
+	// if it is not seekable or its PreviousTagSize fields are broken. They return ErrScanLimitExceeded then.
+	// Zero means no limit, which lets untrusted input of unbounded length keep them running.
+	MaxScanBytes int64
+
+	// OnScript, if set, is called by Walk with the command name and the value of every script tag,
+	// such as onMetaData, onCuePoint or onLastSecond, before the tag is passed to the walk function.
+	// Script tags which fail to decode or have no name are passed without the call.
+	OnScript func(name string, value interface{})
+
+	// SeekThreshold is the size of skipped payloads below which they are read and discarded
+	// even if the source is seekable, for sources where a seek is expensive, like HTTP range requests.
+	// Zero means that a seek is done whenever the payload is not buffered.
+	SeekThreshold int64
+
+	head *Header // the last header read
+	data int64   // offset of the first tag
+	prev int64   // expected PreviousTagSize or -1 if unknown
+	err  error   // error encountered by All
+
+	filter []uint8 // tag types returned by ReadTag, all if empty
+
+	wrap int64 // added to timestamps for AbsoluteTime
+	last int64 // timestamp of the last tag before unwrapping
+	bad  int64 // offset of the tag rejected by ReadTag, whose payload is pending to be skipped, or -1
+
+	stats Stats       // statistics of the tags read
+	seq   []byte      // AVC sequence header payload of the last one read, after the video tag header
+	track *VideoTrack // decoded from seq by VideoTrack, nil if seq is changed
+
+	spare []*[]byte // pointers taken from BufferPool, reused by Release to put buffers back without allocation
 }
 
 // NewReader returns a new reader that reads from r.
 func NewReader(r io.Reader) *Reader {
-	return &Reader{newFileReader(r)}
+	return NewReaderSize(r, readBufferSize)
+}
+
+// NewReaderSize returns a new reader that reads from r using buffer of at least the given size.
+// Larger buffers reduce the number of reads from r for large tags.
+func NewReaderSize(r io.Reader, size int) *Reader {
+	fr := &Reader{fileReader: newFileReader(r, size), prev: -1, bad: -1}
+	fr.threshold = &fr.SeekThreshold
+	return fr
+}
+
+// NewReaderTee returns a new reader that reads from r and writes all bytes read from r to tee,
+// including skipped payloads. Since skipped bytes must be copied, the reader never seeks.
+// Bytes are written to tee as they are buffered, that is possibly ahead of the returned tags.
+func NewReaderTee(r io.Reader, tee io.Writer) *Reader {
+	return NewReader(io.TeeReader(r, tee))
+}
+
+// NewTailReader returns a new reader that follows the growing stream r, like a file being recorded.
+// At the end of r, reads wait for more data polling r every poll interval instead of returning io.EOF,
+// both within a tag and between tags, until ctx is done and they fail with ctx.Err().
+// Since the end of r is never final, the reader never seeks.
+func NewTailReader(ctx context.Context, r io.Reader, poll time.Duration) *Reader {
+	return NewReader(&tailReader{ctx, r, poll})
+}
+
+// Reset discards the reader state and switches it to read from in, reusing the buffer.
+func (r *Reader) Reset(in io.Reader) {
+	r.reset(in)
+	r.head, r.data, r.prev, r.err = nil, 0, -1, nil
+	r.stats, r.seq, r.track = Stats{}, r.seq[:0], nil
+	r.wrap, r.last, r.bad = 0, 0, -1
 }
 
 // ReadHeader reads FLV header
@@ -24,22 +146,54 @@ FLV文件头由9bytes组成，前3个bytes是文件类型，总是“FLV”，
 最后4bytes表示FLV 头的长度，3+1+1+4 = 9。
 */
 func (r *Reader) ReadHeader() (*Header, error) {
+	h, err := r.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	r.data = r.off + r.n
+	return h, nil
+}
+
+// Header returns the last header read, or nil if ReadHeader is not called yet.
+func (r *Reader) Header() *Header {
+	return r.head
+}
+
+func (r *Reader) readHeader() (*Header, error) {
 	b, err := r.next(9)
 	if err != nil {
 		return nil, err
 	}
 	if getUint24(b[0:]) != signature {
-		return nil, fmt.Errorf("flv: incorrect signature: 0x%x", hex.EncodeToString(b[0:3]))
+		return nil, fmt.Errorf("%w: 0x%x", ErrBadSignature, b[0:3])
 	}
 	if b[3] != 1 {
-		return nil, fmt.Errorf("flv: unsupported version: %d", b[3])
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, b[3])
+	}
+	h := &Header{flags: b[4]}
+	off := getUint32(b[5:])
+	if off < 9 {
+		return nil, fmt.Errorf("%w: %d", ErrBadDataOffset, off)
 	}
-	r.skip(int(getUint32(b[5:])) - 9)
-	return &Header{b[4]}, nil
+	if off > 9 {
+		data, err := r.reader(int(off - 9))
+		if err != nil {
+			return nil, err
+		}
+		if h.Extra, err = io.ReadAll(data); err != nil {
+			return nil, err
+		}
+	}
+	r.head, r.prev = h, 0
+	return h, nil
 }
 
-// ReadTag reads FLV tag and returns payload reader.
+// ReadTag reads FLV tag and returns payload reader, which is *PayloadReader.
 // Reader is not valid after next ReadTag.
+// For encrypted tags with the filter bit set, ErrEncrypted is returned and the payload is skipped.
+// The payload of a tag larger than MaxTagSize or rejected by the strict reader is skipped with the error as well,
+// so that the following tags can be read.
+// If the header is not read yet, it is read first, so the stream must start with a valid header then.
 /*
 FLV body由若干个tag 组成。每一个tag第一部分是tag header，tag header长度为11bytes，但是每个tag header前面有4bytes记录着上一个tag的长度。
 tag header：
@@ -57,46 +211,891 @@ tag header：
         ６）tag header 长度为1+3+3+1+3=11。
 */
 func (r *Reader) ReadTag() (*Tag, io.Reader, error) {
-	b, err := r.next(15)
+	tag := &Tag{}
+	data, err := r.ReadTagInto(tag)
 	if err != nil {
 		return nil, nil, err
 	}
-	tag := &Tag{
-		Type:   b[4],
-		Size:   getInt24(b[5:]),
-		Time:   getTime(b[8:]),
-		Stream: getUint24(b[12:]),
+	return tag, data, nil
+}
+
+// PeekTagType returns the type of the next tag without reading it, reading the header first if it is not read yet.
+// The payload of the current tag remains valid if it fits in the buffer, otherwise it is skipped.
+// Tags skipped by the filter are not skipped by PeekTagType.
+func (r *Reader) PeekTagType() (byte, error) {
+	if r.data == 0 {
+		if _, err := r.ReadHeader(); err != nil {
+			return 0, err
+		}
+	}
+	// The type follows PreviousTagSize after the pending bytes of the current tag.
+	n := int(r.l.N) + 4
+	if n >= r.b.Size() {
+		if err := r.validate(); err != nil {
+			return 0, err
+		}
+		n = 4
+	}
+	b, err := r.b.Peek(n + 1)
+	if len(b) <= n {
+		return 0, err
+	}
+	return b[n], nil
+}
+
+// ReadTagInto is like ReadTag but decodes the tag header into t, so it can be reused between calls.
+// All fields of t are overwritten.
+func (r *Reader) ReadTagInto(t *Tag) (io.Reader, error) {
+	for {
+		b, err := r.nextTag()
+		if err != nil {
+			return nil, err
+		}
+		if r.MultiHeader && getUint24(b[4:]) == signature {
+			// Skip the final PreviousTagSize of the segment and read a header of the next one.
+			r.unread()
+			r.skip(4)
+			if _, err = r.readHeader(); err != nil {
+				return nil, err
+			}
+			if b, err = r.nextTag(); err != nil {
+				return nil, err
+			}
+		}
+		t.parseHeader(b[4:])
+		t.PrevTagSize = getUint32(b)
+		t.AbsoluteTime = r.absoluteTime(t.Time)
+		prev := r.prev
+		r.prev = int64(t.Size) + 11
+		if p := int64(t.PrevTagSize); r.Strict && prev >= 0 && p != prev {
+			return nil, r.skipTag(t, fmt.Errorf("flv: previous tag size mismatch: %d, expected %d", p, prev))
+		}
+		if r.Strict && r.head != nil {
+			if err = r.checkFlags(t); err != nil {
+				return nil, r.skipTag(t, err)
+			}
+		}
+		if r.Strict && t.Stream != 0 {
+			return nil, r.skipTag(t, fmt.Errorf("%w: %s in stream %d", errStreamID, t, t.Stream))
+		}
+		known := r.knownType(t.Type)
+		if r.Strict && !known {
+			return nil, r.skipTag(t, fmt.Errorf("%w: %d", errUnknownTagType, t.Type))
+		}
+		if !r.match(t.Type) {
+			r.skip(t.Size)
+			continue
+		}
+		if !known && r.OnUnknownTag != nil {
+			r.OnUnknownTag(t)
+		}
+		if t.Type&TagFilter != 0 {
+			// The payload is skipped, so the following tags can be read.
+			r.skip(t.Size)
+			r.head.Encrypted = true
+			return nil, fmt.Errorf("%w: %s", ErrEncrypted, t)
+		}
+		if r.MaxTagSize > 0 && t.Size > r.MaxTagSize {
+			return nil, r.skipTag(t, fmt.Errorf("%w: %d bytes", ErrTagTooLarge, t.Size))
+		}
+		data, err := r.reader(t.Size)
+		if err != nil {
+			return nil, err
+		}
+		r.stats.add(t, r.b)
+		switch t.Type & 0x1f {
+		case TagTypeVideo:
+			r.peekSequenceHeader(t)
+		case TagTypeScript:
+			if p, _ := r.b.Peek(min(t.Size, len(additionalHeader))); bytes.Equal(p, additionalHeader) {
+				r.head.Encrypted = true
+			}
+		}
+		return data, nil
+	}
+}
+
+// skipTag skips the payload of the tag t rejected with err, so that the following tags can be read,
+// and returns err. The skip is undone by Resync, since the size of a rejected tag may be corrupted.
+func (r *Reader) skipTag(t *Tag, err error) error {
+	r.skip(t.Size)
+	r.bad = r.off
+	return err
+}
+
+// wrapTolerance is the distance in milliseconds from the wrap period within which the timestamps
+// before and after a backward jump must be for the jump to be considered a wraparound.
+// Other backward jumps, like a reset after reconnection, are kept.
+const wrapTolerance = 10000
+
+// absoluteTime returns the timestamp ms continued after wraparounds if UnwrapTimestamps is set.
+func (r *Reader) absoluteTime(ms int64) int64 {
+	if !r.UnwrapTimestamps {
+		return ms
+	}
+	for _, period := range []int64{1 << 24, 1 << 32} {
+		if r.last >= period-wrapTolerance && r.last < period && ms < wrapTolerance {
+			r.wrap += period
+			break
+		}
+	}
+	r.last = ms
+	return ms + r.wrap
+}
+
+// checkFlags returns an error if the tag belongs to the track missing in the header flags.
+func (r *Reader) checkFlags(t *Tag) error {
+	switch t.Type & 0x1f {
+	case TagTypeAudio:
+		if !r.head.HasAudio() {
+			return fmt.Errorf("%w: %s without audio flag", errTrackFlags, t)
+		}
+	case TagTypeVideo:
+		if !r.head.HasVideo() {
+			return fmt.Errorf("%w: %s without video flag", errTrackFlags, t)
+		}
+	}
+	return nil
+}
+
+// SetFilter makes ReadTag skip tags of types other than the given ones, ignoring filter and reserved bits.
+// Calling SetFilter with no types disables filtering.
+func (r *Reader) SetFilter(types ...uint8) {
+	r.filter = r.filter[:0]
+	for _, t := range types {
+		r.filter = append(r.filter, t&0x1f)
+	}
+}
+
+// knownType reports whether the tag type t, ignoring filter and reserved bits, is standard or custom.
+func (r *Reader) knownType(t uint8) bool {
+	switch t &= 0x1f; t {
+	case TagTypeAudio, TagTypeVideo, TagTypeScript:
+		return true
+	}
+	for _, it := range r.CustomTagTypes {
+		if it&0x1f == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reader) match(t uint8) bool {
+	if len(r.filter) == 0 {
+		return true
+	}
+	for _, it := range r.filter {
+		if it == t&0x1f {
+			return true
+		}
 	}
-	data, err := r.reader(tag.Size)
+	return false
+}
+
+// ReadTagBytes reads FLV tag and its payload into a new slice, which remains valid after next ReadTag.
+// Unlike streaming ReadTag, it allocates the whole payload for every tag.
+// If BufferPool is set, the slice is taken from the pool and may be returned to it by Release.
+func (r *Reader) ReadTagBytes() (*Tag, []byte, error) {
+	tag, data, err := r.ReadTag()
 	if err != nil {
 		return nil, nil, err
 	}
-	return tag, data, nil
+	b := r.buffer(tag.Size)
+	if _, err = io.ReadFull(data, b); err != nil {
+		r.Release(b)
+		return nil, nil, err
+	}
+	return tag, b, nil
 }
 
-type fileReader struct {
-	r io.Reader
-	b *bufio.Reader
-	s io.ReadSeeker
+// ReadTagWithPayload is like ReadTagBytes but returns the tag and its payload together,
+// which is convenient for collecting tags.
+func (r *Reader) ReadTagWithPayload() (*TagWithPayload, error) {
+	tag, b, err := r.ReadTagBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &TagWithPayload{tag, b}, nil
+}
+
+// ReadTags reads up to n tags with their payloads, which are read into a few shared buffers.
+// It stops early at the end of the stream and returns the tags read with nil error,
+// io.EOF is returned only if there are no more tags. On other errors, the tags read so far are returned with the error.
+func (r *Reader) ReadTags(n int) ([]TagWithPayload, error) {
+	tags := make([]Tag, n)
+	res := make([]TagWithPayload, 0, n)
+	var buf []byte
+	for i := range tags {
+		t := &tags[i]
+		data, err := r.ReadTagInto(t)
+		if err == io.EOF {
+			if len(res) == 0 {
+				return nil, io.EOF
+			}
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+		if t.Size > cap(buf)-len(buf) {
+			// The payloads read before keep the previous buffer.
+			buf = make([]byte, 0, max(t.Size, 2*cap(buf), readBufferSize))
+		}
+		p := len(buf)
+		buf = buf[:p+t.Size]
+		if _, err = io.ReadFull(data, buf[p:]); err != nil {
+			return res, err
+		}
+		res = append(res, TagWithPayload{t, buf[p:len(buf):len(buf)]})
+	}
+	return res, nil
+}
+
+func (r *Reader) buffer(n int) []byte {
+	if r.BufferPool != nil {
+		if p, ok := r.BufferPool.Get().(*[]byte); ok {
+			b := *p
+			*p = nil
+			r.spare = append(r.spare, p)
+			if cap(b) >= n {
+				return b[:n]
+			}
+		}
+	}
+	return make([]byte, n)
+}
+
+// Release returns the payload slice b obtained from ReadTagBytes to BufferPool, if it is set.
+// Neither b nor any slice sharing its memory may be used after Release.
+func (r *Reader) Release(b []byte) {
+	if r.BufferPool == nil || b == nil {
+		return
+	}
+	var p *[]byte
+	if n := len(r.spare); n > 0 {
+		p = r.spare[n-1]
+		r.spare = r.spare[:n-1]
+	} else {
+		p = new([]byte)
+	}
+	*p = b[:0]
+	r.BufferPool.Put(p)
+}
+
+// All returns an iterator over the remaining tags and their payload readers.
+// The payload reader is valid until the next iteration.
+// Iteration stops at the end of the stream or on the first error, which is then returned by Err.
+func (r *Reader) All() iter.Seq2[*Tag, io.Reader] {
+	return func(yield func(*Tag, io.Reader) bool) {
+		r.err = nil
+		for {
+			tag, data, err := r.ReadTag()
+			if err != nil {
+				if err != io.EOF {
+					r.err = err
+				}
+				return
+			}
+			if !yield(tag, data) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the error encountered by the last All iteration, if any.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// ErrStopWalk is returned by Walk callback to stop walking without error.
+var ErrStopWalk = errors.New("flv: stop walk")
+
+// Walk reads the header unless it is already read and calls fn for each tag.
+// The rest of payload not consumed by fn is skipped.
+// Walk stops at the end of the stream or when fn returns an error.
+// If the error is ErrStopWalk, Walk returns nil.
+func (r *Reader) Walk(fn func(t *Tag, payload io.Reader) error) error {
+	for {
+		tag, data, err := r.ReadTag()
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil && r.OnScript != nil && tag.Type == TagTypeScript {
+			data, err = r.onScript(data)
+		}
+		if err == nil {
+			err = fn(tag, data)
+		}
+		if err == ErrStopWalk {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// onScript reads the script tag payload, calls OnScript if it decodes
+// and returns the payload to be read again by the walk function.
+func (r *Reader) onScript(data io.Reader) (io.Reader, error) {
+	b, err := io.ReadAll(data)
+	if err != nil {
+		return nil, err
+	}
+	if name, value, err := decodeScriptData(bytes.NewReader(b)); err == nil && name != "" {
+		r.OnScript(name, value)
+	}
+	return bytes.NewReader(b), nil
+}
+
+// DumpJSON reads the header unless it is already read and writes JSON array of the remaining tag headers to w,
+// one tag per line. Payloads are skipped.
+func (r *Reader) DumpJSON(w io.Writer) error {
+	sep := "[\n"
+	err := r.Walk(func(t *Tag, _ io.Reader) error {
+		b, err := t.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(w, sep); err == nil {
+			_, err = w.Write(b)
+		}
+		sep = ",\n"
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if sep == "[\n" {
+		_, err = io.WriteString(w, "[]\n")
+	} else {
+		_, err = io.WriteString(w, "\n]\n")
+	}
+	return err
+}
+
+// Validate reads the header unless it is already read and the remaining tags,
+// checking PreviousTagSize fields, tag types, payload sizes and the order of timestamps per tag type.
+// It returns *ValidationError describing the first problem found, or nil if the stream is well-formed.
+// Payloads are read through, so the stream does not need to be seekable.
+// It returns ErrScanLimitExceeded unwrapped if MaxScanBytes is exceeded.
+func (r *Reader) Validate() error {
+	if r.data == 0 {
+		off := r.Offset()
+		if _, err := r.ReadHeader(); err != nil {
+			return &ValidationError{off, err}
+		}
+	}
+	strict := r.Strict
+	r.Strict = true
+	defer func() { r.Strict = strict }()
+	var last [32]int64
+	for i := range last {
+		last[i] = -1
+	}
+	var tag Tag
+	start := r.Offset()
+	for {
+		off := r.Offset()
+		if err := r.checkScan(off - start); err != nil {
+			return err
+		}
+		data, err := r.ReadTagInto(&tag)
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			err = validateTag(&tag, last[:])
+		}
+		if err == nil {
+			_, err = io.Copy(io.Discard, data)
+		}
+		if err != nil {
+			return &ValidationError{off, err}
+		}
+	}
+}
+
+// validateTag checks the order of timestamps, tag types are checked by the strict reader.
+func validateTag(tag *Tag, last []int64) error {
+	t := tag.Type & 0x1f
+	if tag.Time < last[t] {
+		return fmt.Errorf("%w: %s after %dms", errTimestampOrder, tag, last[t])
+	}
+	last[t] = tag.Time
+	return nil
+}
+
+// Offset returns the number of bytes consumed from the underlying reader,
+// counting the rest of the current tag payload as consumed.
+// It is exact at tag boundaries, that is after ReadHeader and ReadTag.
+func (r *Reader) Offset() int64 {
+	return r.off + r.n
+}
+
+// Read reads the remaining bytes of the stream as they are, implementing io.Reader, so that the stream
+// can be relayed by io.Copy, which uses WriteTo. The rest of the current tag payload is skipped first as by WriteTo.
+// Tags cannot be read after Read, since it may stop at any byte.
+func (r *Reader) Read(p []byte) (int, error) {
+	if err := r.validate(); err != nil {
+		return 0, err
+	}
+	n, err := r.b.Read(p)
+	r.off += int64(n)
+	return n, err
+}
+
+// WriteTo writes the remaining bytes of the stream to w as they are, until EOF, implementing io.WriterTo.
+// If the header is not read yet, the output starts with it. Otherwise the rest of the current tag payload
+// is skipped as by ReadTag, so that the output starts with PreviousTagSize following the last tag returned.
+// Buffered bytes are written first, so tags read before are not repeated.
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	if err := r.validate(); err != nil {
+		return 0, err
+	}
+	n, err := r.b.WriteTo(w)
+	r.off += n
+	return n, err
+}
+
+// SkipTag discards the rest of payload of the tag t returned by the last ReadTag.
+// It seeks over the payload if the underlying reader implements io.Seeker.
+func (r *Reader) SkipTag(t *Tag) error {
+	return r.validate()
+}
+
+// Resync scans forward from the current position for a plausible tag boundary, for example after
+// ReadTag failed on a corrupted tag, and positions the reader there, so that ReadTag returns the tag found.
+// A tag is accepted if its type is known, its stream ID is zero and it is followed by matching PreviousTagSize.
+// If the underlying reader does not implement io.Seeker, PreviousTagSize is checked only within the read buffer,
+// so tags larger than the buffer are skipped. Resync scans at most 1 MiB and returns io.EOF at the end of the stream.
+func (r *Reader) Resync() error {
+	if r.n > 0 && r.off == r.bad {
+		// Scan the payload of the rejected tag instead of skipping it.
+		r.l.N, r.n = 15, 15
+	}
+	if err := r.validate(); err != nil {
+		return err
+	}
+	for scanned := 0; scanned < maxResyncScan; {
+		buf, err := r.b.Peek(r.b.Size())
+		if len(buf) < 15 {
+			return unexpectedEOF(err)
+		}
+		for i := 0; i+15 <= len(buf); i++ {
+			ok, err := r.isTagBoundary(buf, i)
+			if err != nil {
+				return err
+			}
+			if ok {
+				r.b.Discard(i)
+				r.off += int64(i)
+				r.prev = -1
+				return nil
+			}
+			if r.b.Buffered() < len(buf) {
+				// The buffer is reset by checking PreviousTagSize beyond it.
+				if buf, err = r.b.Peek(r.b.Size()); len(buf) < i+15 {
+					return unexpectedEOF(err)
+				}
+			}
+		}
+		if err != nil {
+			return io.EOF
+		}
+		n := len(buf) - 14
+		r.b.Discard(n)
+		r.off += int64(n)
+		scanned += n
+	}
+	return errNoTagBoundary
+}
+
+// FindSignature skips bytes preceding the FLV signature, so that the header can be read by ReadHeader
+// from a stream starting at an unknown offset before it. It scans at most maxScan bytes before the signature
+// and returns ErrNoSignature if the signature is not found there.
+// Offsets are still relative to the start of the stream, including the skipped bytes.
+func (r *Reader) FindSignature(maxScan int) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+	sig := []byte("FLV")
+	for scanned := 0; scanned <= maxScan; {
+		buf, err := r.b.Peek(min(r.b.Size(), maxScan-scanned+len(sig)))
+		if i := bytes.Index(buf, sig); i >= 0 {
+			r.b.Discard(i)
+			r.off += int64(i)
+			return nil
+		}
+		if err != nil || len(buf) < len(sig) {
+			break
+		}
+		n := len(buf) - len(sig) + 1
+		r.b.Discard(n)
+		r.off += int64(n)
+		scanned += n
+	}
+	return fmt.Errorf("%w within %d bytes", ErrNoSignature, maxScan)
+}
+
+// isTagBoundary reports whether buf[i:] holds PreviousTagSize and the header of a plausible tag.
+// The buffer starts at the current position. If the tag extends beyond it, the following PreviousTagSize
+// is read by seeking, which resets the buffer.
+func (r *Reader) isTagBoundary(buf []byte, i int) (bool, error) {
+	h := buf[i+4 : i+15]
+	if t := h[0] & 0x1f; h[0]&0xc0 != 0 || t != TagTypeAudio && t != TagTypeVideo && t != TagTypeScript || getUint24(h[8:]) != 0 {
+		return false, nil
+	}
+	size := getInt24(h[1:])
+	end := i + 15 + size
+	if end+4 <= len(buf) {
+		return getUint32(buf[end:]) == uint32(size)+11, nil
+	}
+	if r.s == nil {
+		return false, nil
+	}
+	off := r.off
+	if err := r.seek(off + int64(end)); err != nil {
+		return false, err
+	}
+	b, err := r.next(4)
+	ok := err == nil && getUint32(b) == uint32(size)+11
+	return ok, r.seek(off)
+}
+
+// ReadHeaderContext is like ReadHeader but returns ctx.Err() if ctx is done.
+func (r *Reader) ReadHeaderContext(ctx context.Context) (*Header, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.ReadHeader()
+}
+
+// ReadTagContext is like ReadTag but returns ctx.Err() if ctx is done.
+// Reads from the returned payload reader fail with ctx.Err() as well.
+func (r *Reader) ReadTagContext(ctx context.Context) (*Tag, io.Reader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	tag, data, err := r.ReadTag()
+	if err != nil {
+		return nil, nil, err
+	}
+	return tag, &contextReader{ctx, data}, nil
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+type tailReader struct {
+	ctx  context.Context
+	r    io.Reader
+	poll time.Duration
+}
+
+func (r *tailReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.r.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		t := time.NewTimer(r.poll)
+		select {
+		case <-r.ctx.Done():
+			t.Stop()
+			return 0, r.ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// SeekTime positions the reader at the first tag with timestamp at or after ms milliseconds
+// and returns its header. The tag is returned again by the following ReadTag.
+// It returns io.EOF if there is no such tag.
+// The underlying reader must implement io.Seeker. The header is read first unless it is already read.
+func (r *Reader) SeekTime(ms int64) (*Tag, error) {
+	if r.s == nil {
+		return nil, errNotSeekable
+	}
+	if r.data == 0 {
+		if _, err := r.ReadHeader(); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.seek(r.data); err != nil {
+		return nil, err
+	}
+	r.prev = 0
+	for {
+		tag, err := r.peekTag()
+		if err != nil {
+			return nil, err
+		}
+		if tag.Time >= ms {
+			return tag, nil
+		}
+		r.skip(15 + tag.Size)
+		r.prev = int64(tag.Size) + 11
+	}
+}
+
+// Duration reads the header unless it is already read and returns the timestamp of the last audio or video tag.
+// If the underlying reader implements io.Seeker, tags are read backward from the end of the stream
+// using PreviousTagSize fields and the reader position is preserved.
+// Otherwise the remaining tags are read. Either scan is limited by MaxScanBytes.
+func (r *Reader) Duration() (time.Duration, error) {
+	if r.data == 0 {
+		if _, err := r.ReadHeader(); err != nil {
+			return 0, err
+		}
+	}
+	if r.s == nil {
+		return r.scanDuration()
+	}
+	ms, ok, err := r.lastTime()
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	// PreviousTagSize chain is broken, scan from the first tag.
+	off := r.Offset()
+	if err = r.seek(r.data); err != nil {
+		return 0, err
+	}
+	d, err := r.scanDuration()
+	if err != nil {
+		return 0, err
+	}
+	return d, r.seek(off)
+}
+
+// checkScan returns ErrScanLimitExceeded if n bytes scanned exceed MaxScanBytes.
+func (r *Reader) checkScan(n int64) error {
+	if r.MaxScanBytes > 0 && n > r.MaxScanBytes {
+		return fmt.Errorf("%w: %d bytes", ErrScanLimitExceeded, r.MaxScanBytes)
+	}
+	return nil
+}
+
+// scanDuration reads only the remaining tag headers, so that the statistics, the filter
+// and the state checked by ReadTag are not affected by the scan.
+func (r *Reader) scanDuration() (time.Duration, error) {
+	head, prev := r.head, r.prev
+	defer func() { r.head, r.prev = head, prev }()
+	var ms int64
+	start := r.Offset()
+	for {
+		if err := r.checkScan(r.Offset() - start); err != nil {
+			return 0, err
+		}
+		b, err := r.nextTag()
+		if err == io.EOF {
+			return time.Duration(ms) * time.Millisecond, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if r.MultiHeader && getUint24(b[4:]) == signature {
+			r.unread()
+			r.skip(4)
+			if _, err = r.readHeader(); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if t := b[4] & 0x1f; (t == TagTypeAudio || t == TagTypeVideo) && getTime(b[8:]) > ms {
+			ms = getTime(b[8:])
+		}
+		r.skip(getInt24(b[5:]))
+	}
+}
+
+// lastTime reads tags backward from the end of the stream and returns the timestamp of the last media tag.
+func (r *Reader) lastTime() (int64, bool, error) {
+	cur, err := r.s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false, err
+	}
+	// Absolute offset of the first tag.
+	start := cur - int64(r.b.Buffered()) - (r.off + r.n - r.l.N) + r.data
+	pos, err := r.s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, false, err
+	}
+	ms, ok, err := r.readBackward(start, pos)
+	if _, e := r.s.Seek(cur, io.SeekStart); err == nil {
+		err = e
+	}
+	return ms, ok, err
+}
+
+func (r *Reader) readBackward(start, pos int64) (int64, bool, error) {
+	var b [11]byte
+	end := pos
+	for pos-4 > start {
+		if err := r.checkScan(end - pos); err != nil {
+			return 0, false, err
+		}
+		if _, err := r.s.Seek(pos-4, io.SeekStart); err != nil {
+			return 0, false, err
+		}
+		if _, err := io.ReadFull(r.s, b[:4]); err != nil {
+			return 0, false, err
+		}
+		size := int64(getUint32(b[:]))
+		if size < 11 || pos-4-size < start+4 {
+			return 0, false, nil
+		}
+		pos -= 4 + size
+		if _, err := r.s.Seek(pos, io.SeekStart); err != nil {
+			return 0, false, err
+		}
+		if _, err := io.ReadFull(r.s, b[:]); err != nil {
+			return 0, false, err
+		}
+		if int64(getInt24(b[1:]))+11 != size {
+			return 0, false, nil
+		}
+		if t := b[0] & 0x1f; t == TagTypeAudio || t == TagTypeVideo {
+			return getTime(b[4:]), true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// peekTag reads the next tag header without consuming it.
+func (r *Reader) peekTag() (*Tag, error) {
+	b, err := r.nextTag()
+	if err != nil {
+		return nil, err
+	}
+	r.unread()
+	return parseTag(b), nil
+}
+
+// nextTag returns PreviousTagSize and the tag header, reading the header first if it is not read yet.
+// It returns io.EOF at the end of the stream, where the final PreviousTagSize may be missing or truncated
+// unless the reader is strict, since some encoders omit it.
+func (r *Reader) nextTag() ([]byte, error) {
+	if r.data == 0 {
+		if _, err := r.ReadHeader(); err != nil {
+			return nil, err
+		}
+	}
+	b, err := r.next(15)
+	if err == io.EOF {
+		n := r.b.Buffered()
+		if n > 4 || r.Strict && n != 4 {
+			return nil, fmt.Errorf("%w: %d bytes left", ErrShortTag, n)
+		}
+	}
+	return b, err
+}
+
+// parseTag decodes the tag header following PreviousTagSize.
+func parseTag(b []byte) *Tag {
+	t := &Tag{PrevTagSize: getUint32(b)}
+	t.parseHeader(b[4:])
+	t.AbsoluteTime = t.Time
+	return t
+}
+
+// TruncatedError is returned by the payload reader if the stream ends before the end of the tag payload.
+type TruncatedError struct {
+	Missing int64 // number of missing payload bytes
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("flv: truncated tag: %d bytes missing", e.Missing)
+}
+
+func (e *TruncatedError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+// ValidationError is returned by Validate for a malformed stream.
+type ValidationError struct {
+	Offset int64 // offset of the malformed header or PreviousTagSize field preceding the malformed tag
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v at offset %d", e.Err, e.Offset)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// PayloadReader is the payload reader returned by ReadTag and ReadTagInto.
+// Like the payload, it is valid only until the next read from the Reader.
+type PayloadReader struct {
 	l *io.LimitedReader
 }
 
-func newFileReader(r io.Reader) *fileReader {
-	b, ok := r.(*bufio.Reader)
-	if !ok {
-		b = bufio.NewReader(r)
+// Remaining returns the number of payload bytes not read yet.
+func (r *PayloadReader) Remaining() int64 {
+	return r.l.N
+}
+
+func (r *PayloadReader) Read(p []byte) (int, error) {
+	n, err := r.l.Read(p)
+	if err == io.EOF && r.l.N > 0 {
+		err = &TruncatedError{r.l.N}
 	}
+	return n, err
+}
+
+type fileReader struct {
+	r   io.Reader
+	b   *bufio.Reader
+	s   io.ReadSeeker
+	l   *io.LimitedReader
+	p   *PayloadReader
+	off int64 // offset of the pending region
+	n   int64 // size of the pending region
+
+	threshold *int64 // skips smaller than it are discarded, nil if none
+}
+
+var readBufferSize = 4096
+
+func newFileReader(r io.Reader, size int) *fileReader {
+	b := bufio.NewReaderSize(r, size)
 	s, _ := r.(io.ReadSeeker)
-	return &fileReader{r, b, s, &io.LimitedReader{R: b, N: 0}}
+	l := &io.LimitedReader{R: b, N: 0}
+	return &fileReader{r: r, b: b, s: s, l: l, p: &PayloadReader{l}}
+}
+
+func (r *fileReader) reset(in io.Reader) {
+	r.r = in
+	r.b.Reset(in)
+	r.s, _ = in.(io.ReadSeeker)
+	r.l.N, r.off, r.n = 0, 0, 0
 }
 
 func (r *fileReader) validate() error {
+	r.off += r.n
+	r.n = 0
 	if r.l.N <= 0 {
 		return nil
 	}
 	b, n := int64(r.b.Buffered()), r.l.N
 	r.l.N = 0
-	if b < n && r.s != nil {
+	if b < n && r.s != nil && (r.threshold == nil || n >= *r.threshold) {
 		r.b.Reset(r.r)
 		_, err := r.s.Seek(n-b, io.SeekCurrent)
 		return err
@@ -114,13 +1113,19 @@ func (r *fileReader) next(n int) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	r.l.N = int64(n)
+	r.l.N, r.n = int64(n), int64(n)
 	return buf, err
 }
 
+// unread makes bytes returned by the last next call to be returned again.
+func (r *fileReader) unread() {
+	r.l.N, r.n = 0, 0
+}
+
 func (r *fileReader) skip(n int) {
 	if n > 0 {
 		r.l.N += int64(n)
+		r.n += int64(n)
 	}
 }
 
@@ -128,15 +1133,39 @@ func (r *fileReader) reader(n int) (io.Reader, error) {
 	if err := r.validate(); err != nil {
 		return nil, err
 	}
-	r.l.N = int64(n)
-	return r.l, nil
+	r.l.N, r.n = int64(n), int64(n)
+	return r.p, nil
 }
 
+// seek positions the reader at the offset relative to the start of the stream.
+func (r *fileReader) seek(off int64) error {
+	if r.s == nil {
+		return errNotSeekable
+	}
+	p := r.off + r.n - r.l.N + int64(r.b.Buffered())
+	r.b.Reset(r.r)
+	r.off, r.n, r.l.N = off, 0, 0
+	_, err := r.s.Seek(off-p, io.SeekCurrent)
+	return err
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// getInt24 decodes 24-bit unsigned integer, the result is never negative since int has at least 32 bits.
 func getInt24(b []byte) int {
 	_ = b[2]
 	return int(b[2]) | int(b[1])<<8 | int(b[0])<<16
 }
 
+func getSignedInt24(b []byte) int32 {
+	return int32(getUint24(b)<<8) >> 8
+}
+
 func getUint24(b []byte) uint32 {
 	_ = b[2]
 	return uint32(b[2]) | uint32(b[1])<<8 | uint32(b[0])<<16
@@ -147,11 +1176,14 @@ func putUint24(b []byte, v uint32) {
 	b[2], b[1], b[0] = uint8(v), uint8(v>>8), uint8(v>>16)
 }
 
+// getTime decodes 24-bit timestamp followed by the extended byte holding its upper 8 bits.
+// The result is in range [0, 1<<32) and wraps to zero after about 49.7 days.
 func getTime(b []byte) int64 {
 	_ = b[3]
 	return int64(b[2]) | int64(b[1])<<8 | int64(b[0])<<16 | int64(b[3])<<24
 }
 
+// putTime encodes the lower 32 bits of the timestamp, upper 8 of them go to the extended byte.
 func putTime(b []byte, v int64) {
 	_ = b[3]
 	b[2], b[1], b[0], b[3] = uint8(v), uint8(v>>8), uint8(v>>16), uint8(v>>24)
@@ -0,0 +1,1674 @@
+package flv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReaderSeekTime(t *testing.T) {
+	// The header is read by the first SeekTime.
+	r := NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	for _, it := range []struct {
+		ms   int64
+		typ  uint8
+		time int64
+	}{
+		{20, TagTypeAudio, 23},
+		{0, TagTypeScript, 0},
+		{33, TagTypeVideo, 33},
+		{34, TagTypeVideo, 0x1234567},
+		{1, TagTypeAudio, 23},
+	} {
+		tag, err := r.SeekTime(it.ms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Type != it.typ || tag.Time != it.time {
+			t.Errorf("seek %d: got: %v, expected: %d@%d", it.ms, tag, it.typ, it.time)
+		}
+		next, _, err := r.ReadTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *next != *tag {
+			t.Errorf("seek %d: got next: %v, expected: %v", it.ms, next, tag)
+		}
+	}
+	if _, err := r.SeekTime(0x2000000); err != io.EOF {
+		t.Errorf("got: %v, expected: %v", err, io.EOF)
+	}
+	r = NewReader(bytes.NewBuffer(buildFLV(5, testTags...)))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.SeekTime(0); err != errNotSeekable {
+		t.Errorf("got: %v, expected: %v", err, errNotSeekable)
+	}
+}
+
+func TestReaderSeekLargeTags(t *testing.T) {
+	big := make([]byte, 10000)
+	r := NewReader(bytes.NewReader(buildFLV(1, testTag{TagTypeVideo, 0, big}, testTag{TagTypeVideo, 40, big}, testTag{TagTypeVideo, 80, big})))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for _, ms := range []int64{80, 0, 40} {
+		tag, err := r.SeekTime(ms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Time != ms || tag.Size != len(big) {
+			t.Errorf("got: %v, expected time %d", tag, ms)
+		}
+	}
+}
+
+func TestReaderContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	if _, err := r.ReadHeaderContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.ReadTagContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, data, err := r.ReadTagContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err = data.Read(make([]byte, 1)); err != context.Canceled {
+		t.Errorf("got: %v, expected: %v", err, context.Canceled)
+	}
+	if _, _, err = r.ReadTagContext(ctx); err != context.Canceled {
+		t.Errorf("got: %v, expected: %v", err, context.Canceled)
+	}
+}
+
+func TestReaderSkipTag(t *testing.T) {
+	r := NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range testTags {
+		tag, data, err := r.ReadTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			data.Read(make([]byte, 1))
+		}
+		if err = r.SkipTag(tag); err != nil {
+			t.Fatal(err)
+		}
+		if tag.Time != testTags[i].time {
+			t.Errorf("got: %v, expected time %d", tag, testTags[i].time)
+		}
+	}
+	if _, _, err := r.ReadTag(); err != io.EOF {
+		t.Errorf("got: %v, expected: %v", err, io.EOF)
+	}
+}
+
+func benchmarkFLV() []byte {
+	var tags []testTag
+	for i := 0; i < 100; i++ {
+		tags = append(tags, testTag{TagTypeVideo, int64(i * 40), make([]byte, 64<<10)}, testTag{TagTypeAudio, int64(i * 40), make([]byte, 256)})
+	}
+	return buildFLV(5, tags...)
+}
+
+func BenchmarkReaderCopyDiscard(b *testing.B) {
+	in := benchmarkFLV()
+	b.SetBytes(int64(len(in)))
+	for i := 0; i < b.N; i++ {
+		r := NewReader(bytes.NewReader(in))
+		r.ReadHeader()
+		for {
+			_, data, err := r.ReadTag()
+			if err != nil {
+				break
+			}
+			io.Copy(io.Discard, data)
+		}
+	}
+}
+
+func BenchmarkReaderSkipTag(b *testing.B) {
+	in := benchmarkFLV()
+	b.SetBytes(int64(len(in)))
+	for i := 0; i < b.N; i++ {
+		r := NewReader(bytes.NewReader(in))
+		r.ReadHeader()
+		for {
+			tag, _, err := r.ReadTag()
+			if err != nil {
+				break
+			}
+			r.SkipTag(tag)
+		}
+	}
+}
+
+func BenchmarkReaderReadTag(b *testing.B) {
+	in := benchmarkFLV()
+	b.Run("New", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r := NewReader(bytes.NewReader(in))
+			r.ReadHeader()
+			for {
+				if _, _, err := r.ReadTag(); err != nil {
+					break
+				}
+			}
+		}
+	})
+	b.Run("Into", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r := NewReader(bytes.NewReader(in))
+			r.ReadHeader()
+			var tag Tag
+			for {
+				if _, err := r.ReadTagInto(&tag); err != nil {
+					break
+				}
+			}
+		}
+	})
+}
+
+func TestReaderReadTagInto(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	r := NewReader(bytes.NewReader(in))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	tag := Tag{Type: 0xff, Size: -1, Time: -1, Stream: 0xffffff, PrevTagSize: 1, AbsoluteTime: -1}
+	prev := uint32(0)
+	for i, it := range testTags {
+		data, err := r.ReadTagInto(&tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := (Tag{Type: it.typ, Size: len(it.data), Time: it.time, PrevTagSize: prev, AbsoluteTime: it.time}); tag != expected {
+			t.Errorf("tag %d: got: %+v, expected: %+v", i, tag, expected)
+		}
+		prev = uint32(len(it.data)) + 11
+		if b, _ := io.ReadAll(data); !bytes.Equal(b, it.data) {
+			t.Errorf("tag %d: got payload: %x, expected: %x", i, b, it.data)
+		}
+	}
+	// The header is read by the first ReadTagInto, which allocates only it.
+	br := bytes.NewReader(nil)
+	allocs := testing.AllocsPerRun(10, func() {
+		br.Reset(in)
+		r.Reset(br)
+		for {
+			if _, err := r.ReadTagInto(&tag); err != nil {
+				break
+			}
+		}
+	})
+	if allocs != 1 {
+		t.Errorf("got %v allocations, expected 1", allocs)
+	}
+}
+
+func TestReaderStrict(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	// Corrupt PreviousTagSize of the third tag.
+	p := 13 + 11 + len(testTags[0].data) + 4 + 11 + len(testTags[1].data)
+	putUint32(in[p:], 1234)
+	for _, strict := range []bool{false, true} {
+		r := NewReader(bytes.NewReader(in))
+		r.Strict = strict
+		if _, err := r.ReadHeader(); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		n := 0
+		for ; err == nil; n++ {
+			_, _, err = r.ReadTag()
+		}
+		if strict {
+			if n != 3 || err == nil || err == io.EOF || !strings.Contains(err.Error(), "1234") {
+				t.Errorf("strict: got %v after %d tags", err, n-1)
+			}
+			// The payload of the rejected tag is skipped and the following tags are read.
+			for _, it := range testTags[3:] {
+				_, data, err := r.ReadTag()
+				if err != nil {
+					t.Fatalf("strict: %v", err)
+				}
+				if b, _ := io.ReadAll(data); !bytes.Equal(b, it.data) {
+					t.Errorf("strict: got: %x, expected: %x", b, it.data)
+				}
+			}
+			if _, _, err = r.ReadTag(); err != io.EOF {
+				t.Errorf("strict: got: %v, expected: %v", err, io.EOF)
+			}
+		} else if err != io.EOF || n-1 != len(testTags) {
+			t.Errorf("lenient: got %v after %d tags", err, n-1)
+		}
+	}
+}
+
+func TestReaderAll(t *testing.T) {
+	r := NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for tag, data := range r.All() {
+		if n == 0 {
+			data.Read(make([]byte, 2))
+		}
+		if tag.Time != testTags[n].time {
+			t.Errorf("got: %v, expected time %d", tag, testTags[n].time)
+		}
+		if n++; n == 2 {
+			break
+		}
+	}
+	for tag, data := range r.All() {
+		b, _ := io.ReadAll(data)
+		if !bytes.Equal(b, testTags[n].data) {
+			t.Errorf("%v: got: %x, expected: %x", tag, b, testTags[n].data)
+		}
+		n++
+	}
+	if err := r.Err(); err != nil || n != len(testTags) {
+		t.Errorf("got %v after %d tags", err, n)
+	}
+
+	in := buildFLV(5, testTags...)
+	putUint32(in[len(in)-4-len(testTags[5].data)-15:], 1)
+	r = NewReader(bytes.NewReader(in))
+	r.Strict = true
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for range r.All() {
+	}
+	if r.Err() == nil {
+		t.Error("expected error on corrupted stream")
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	for _, it := range []struct {
+		b   []byte
+		err error
+	}{
+		{[]byte("FLX\x01\x05\x00\x00\x00\x09"), ErrBadSignature},
+		{[]byte("FLV\x02\x05\x00\x00\x00\x09"), ErrUnsupportedVersion},
+		{in[:len(in)-10], ErrShortTag},
+		{[]byte("FLV\x01\x05\x00\x00\x00\x08\x00\x00\x00\x00"), ErrBadDataOffset},
+		{[]byte("FLV\x01\x05\x00\x00\x00\x0d\x01\x02"), io.ErrUnexpectedEOF},
+	} {
+		r := NewReader(bytes.NewReader(it.b))
+		_, err := r.ReadHeader()
+		for err == nil {
+			_, _, err = r.ReadTag()
+		}
+		if !errors.Is(err, it.err) {
+			t.Errorf("got: %v, expected: %v", err, it.err)
+		}
+	}
+}
+
+func TestReaderDataOffset(t *testing.T) {
+	for _, extra := range [][]byte{nil, {1, 2, 3, 4}} {
+		in := buildFLV(5, testTags...)
+		putUint32(in[5:], uint32(9+len(extra)))
+		in = append(in[:9], append(append([]byte{}, extra...), in[9:]...)...)
+		r := NewReader(bytes.NewReader(in))
+		h, err := r.ReadHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(h.Extra, extra) || h.Flags() != 5 {
+			t.Errorf("got extra: %x, expected: %x", h.Extra, extra)
+		}
+		if r.Offset() != int64(9+len(extra)) {
+			t.Errorf("got offset %d, expected %d", r.Offset(), 9+len(extra))
+		}
+		out := &bytes.Buffer{}
+		w := NewWriter(out)
+		if err = w.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		for tag, data := range r.All() {
+			if err = w.WriteTag(tag, data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = r.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), in) {
+			t.Errorf("got: %x, expected: %x", out.Bytes(), in)
+		}
+	}
+}
+
+func TestReaderFilter(t *testing.T) {
+	in := benchmarkFLV()
+	for _, it := range []struct {
+		types []uint8
+		n     int
+	}{
+		{[]uint8{TagTypeVideo}, 100},
+		{[]uint8{TagTypeAudio, TagTypeScript}, 100},
+		{[]uint8{TagTypeScript}, 0},
+		{nil, 200},
+	} {
+		r := NewReader(bytes.NewReader(in))
+		r.Strict = true
+		r.SetFilter(TagTypeAudio)
+		r.SetFilter(it.types...)
+		n := 0
+		err := r.Walk(func(tag *Tag, _ io.Reader) error {
+			if !r.match(tag.Type) {
+				t.Errorf("%v: got %s", it.types, tag)
+			}
+			n++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != it.n {
+			t.Errorf("%v: got %d tags, expected %d", it.types, n, it.n)
+		}
+	}
+}
+
+func TestReaderReset(t *testing.T) {
+	r := NewReader(bytes.NewBuffer(buildFLV(5, testTags...)))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.ReadTag(); err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range [][]testTag{testTags[3:], testTags[1:3]} {
+		r.Reset(bytes.NewReader(buildFLV(1, in...)))
+		h, err := r.ReadHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Flags() != 1 {
+			t.Errorf("got flags: %d", h.Flags())
+		}
+		n := 0
+		for tag, data := range r.All() {
+			b, _ := io.ReadAll(data)
+			if tag.Time != in[n].time || !bytes.Equal(b, in[n].data) {
+				t.Errorf("got: %v %x, expected: %x", tag, b, in[n].data)
+			}
+			n++
+		}
+		if r.Err() != nil || n != len(in) {
+			t.Errorf("got %v after %d tags", r.Err(), n)
+		}
+		if _, err = r.SeekTime(0); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReaderOffset(t *testing.T) {
+	for _, in := range []io.Reader{bytes.NewReader(buildFLV(5, testTags...)), bytes.NewBuffer(buildFLV(5, testTags...))} {
+		r := NewReader(in)
+		if _, err := r.ReadHeader(); err != nil {
+			t.Fatal(err)
+		}
+		off := int64(9)
+		if r.Offset() != off {
+			t.Errorf("got: %d, expected: %d", r.Offset(), off)
+		}
+		for i, it := range testTags {
+			_, data, err := r.ReadTag()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i%2 == 1 {
+				io.ReadAll(data)
+			}
+			off += 15 + int64(len(it.data))
+			if r.Offset() != off {
+				t.Errorf("tag %d: got: %d, expected: %d", i, r.Offset(), off)
+			}
+		}
+	}
+}
+
+func TestReaderMultiHeader(t *testing.T) {
+	in := append(buildFLV(5, testTags[:3]...), buildFLV(5, testTags[3:]...)...)
+	for _, multi := range []bool{false, true} {
+		r := NewReader(bytes.NewReader(in))
+		r.MultiHeader = multi
+		r.Strict = true
+		if _, err := r.ReadHeader(); err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for tag := range r.All() {
+			if multi && tag.Time != testTags[n].time {
+				t.Errorf("got: %v, expected time %d", tag, testTags[n].time)
+			}
+			n++
+		}
+		if multi && (r.Err() != nil || n != len(testTags)) {
+			t.Errorf("got %v after %d tags", r.Err(), n)
+		}
+		if !multi && n == len(testTags) {
+			t.Errorf("expected misread without MultiHeader")
+		}
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	in = in[:len(in)-4-3]
+	r := NewReader(bytes.NewReader(in))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range testTags {
+		_, data, err := r.ReadTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(data)
+		if i < len(testTags)-1 {
+			if err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		var e *TruncatedError
+		if !errors.As(err, &e) || e.Missing != 3 || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("got: %v, expected 3 bytes missing", err)
+		}
+		if len(b) != len(testTags[i].data)-3 {
+			t.Errorf("got %d bytes", len(b))
+		}
+	}
+}
+
+func TestReaderReadTagBytes(t *testing.T) {
+	r := NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var payloads [][]byte
+	for range testTags {
+		tag, b, err := r.ReadTagBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Size != len(b) {
+			t.Errorf("got %d bytes, expected %d", len(b), tag.Size)
+		}
+		payloads = append(payloads, b)
+	}
+	if _, _, err := r.ReadTagBytes(); err != io.EOF {
+		t.Errorf("got: %v, expected: %v", err, io.EOF)
+	}
+	for i, it := range testTags {
+		if !bytes.Equal(payloads[i], it.data) {
+			t.Errorf("tag %d: got: %x, expected: %x", i, payloads[i], it.data)
+		}
+	}
+	r = NewReader(bytes.NewReader(buildFLV(5, testTags[:2]...)[:13+11+5]))
+	r.ReadHeader()
+	if _, _, err := r.ReadTagBytes(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got: %v, expected: %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestReaderDumpJSON(t *testing.T) {
+	for _, it := range []struct {
+		tags []testTag
+		s    string
+	}{
+		{nil, "[]\n"},
+		{testTags[:3], `[
+{"type":"script","size":14,"time":0,"stream":0},
+{"type":"video","size":8,"time":0,"stream":0},
+{"type":"audio","size":4,"time":0,"stream":0}
+]
+`},
+	} {
+		out := &bytes.Buffer{}
+		if err := NewReader(bytes.NewReader(buildFLV(5, it.tags...))).DumpJSON(out); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != it.s {
+			t.Errorf("got: %s, expected: %s", out, it.s)
+		}
+		var v []map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &v); err != nil || len(v) != len(it.tags) {
+			t.Errorf("got %d tags: %v", len(v), err)
+		}
+	}
+}
+
+func TestReaderTee(t *testing.T) {
+	in := benchmarkFLV()
+	out := &bytes.Buffer{}
+	r := NewReaderTee(bytes.NewReader(in), out)
+	if r.s != nil {
+		t.Fatal("tee reader is seekable")
+	}
+	n := 0
+	err := r.Walk(func(tag *Tag, data io.Reader) error {
+		// Read some payloads partially and skip the rest.
+		if n++; n%3 == 0 {
+			_, err := io.CopyN(io.Discard, data, 10)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), in) {
+		t.Errorf("got %d bytes, expected %d", out.Len(), len(in))
+	}
+}
+
+func TestReaderReadTagWithPayload(t *testing.T) {
+	r := NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	r.ReadHeader()
+	var tags []*TagWithPayload
+	for {
+		it, err := r.ReadTagWithPayload()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		tags = append(tags, it)
+	}
+	if len(tags) != len(testTags) {
+		t.Fatalf("got %d tags, expected %d", len(tags), len(testTags))
+	}
+	for i, it := range testTags {
+		if tags[i].Tag.Type != it.typ || tags[i].Tag.Time != it.time || !bytes.Equal(tags[i].Data, it.data) {
+			t.Errorf("tag %d: got: %s %x, expected: %x", i, tags[i].Tag, tags[i].Data, it.data)
+		}
+	}
+	c := tags[0].Tag.Clone()
+	c.Time = 100
+	if *c == *tags[0].Tag || c.Type != tags[0].Tag.Type {
+		t.Errorf("got clone: %+v of %+v", c, tags[0].Tag)
+	}
+}
+
+func TestReaderBufferPool(t *testing.T) {
+	n := 0
+	pool := &sync.Pool{New: func() interface{} {
+		n++
+		b := make([]byte, 0, 64)
+		return &b
+	}}
+	in := buildFLV(5, testTags...)
+	r := NewReader(bytes.NewReader(in))
+	r.BufferPool = pool
+	var released *byte
+	reused := 0
+	for range 4 {
+		r.Reset(bytes.NewReader(in))
+		for i, it := range testTags {
+			_, b, err := r.ReadTagBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, it.data) {
+				t.Errorf("tag %d: got: %x, expected: %x", i, b, it.data)
+			}
+			if cap(b) != 64 {
+				t.Errorf("tag %d: buffer is not taken from pool", i)
+			}
+			// The backing array of the released buffer comes back unless the pool drops it.
+			if p := &b[:1][0]; p == released {
+				reused++
+			} else {
+				released = p
+			}
+			r.Release(b)
+		}
+	}
+	if reused == 0 || n+reused != 4*len(testTags) {
+		t.Errorf("got %d new and %d reused buffers", n, reused)
+	}
+	r.BufferPool = nil
+	r.Release(make([]byte, 10))
+}
+
+func BenchmarkReaderReadTagBytes(b *testing.B) {
+	in := benchmarkFLV()
+	for _, pool := range []*sync.Pool{nil, {}} {
+		b.Run(fmt.Sprint("pool=", pool != nil), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(in)))
+			for i := 0; i < b.N; i++ {
+				r := NewReader(bytes.NewReader(in))
+				r.BufferPool = pool
+				r.ReadHeader()
+				for {
+					_, data, err := r.ReadTagBytes()
+					if err != nil {
+						break
+					}
+					r.Release(data)
+				}
+			}
+		})
+	}
+}
+
+func TestReaderReadTags(t *testing.T) {
+	r := NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	var got []TagWithPayload
+	for _, n := range []int{4, 2} {
+		tags, err := r.ReadTags(4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tags) != n {
+			t.Fatalf("got %d tags, expected %d", len(tags), n)
+		}
+		got = append(got, tags...)
+	}
+	if tags, err := r.ReadTags(4); err != io.EOF || tags != nil {
+		t.Errorf("got: %v, %v, expected: %v", tags, err, io.EOF)
+	}
+	for i, it := range testTags {
+		if got[i].Tag.Type != it.typ || got[i].Tag.Time != it.time || !bytes.Equal(got[i].Data, it.data) {
+			t.Errorf("tag %d: got: %+v %x, expected: %+v", i, got[i].Tag, got[i].Data, it)
+		}
+	}
+	in := buildFLV(5, testTags...)
+	r = NewReader(bytes.NewReader(in[:len(in)-6]))
+	if tags, err := r.ReadTags(10); !errors.Is(err, io.ErrUnexpectedEOF) || len(tags) != 5 {
+		t.Errorf("got %d tags, %v, expected %d tags, %v", len(tags), err, 5, io.ErrUnexpectedEOF)
+	}
+}
+
+func BenchmarkReaderReadTags(b *testing.B) {
+	in := benchmarkFLV()
+	b.Run("single", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(in)))
+		for i := 0; i < b.N; i++ {
+			r := NewReader(bytes.NewReader(in))
+			for {
+				if _, err := r.ReadTagWithPayload(); err != nil {
+					break
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(in)))
+		for i := 0; i < b.N; i++ {
+			r := NewReader(bytes.NewReader(in))
+			for {
+				if _, err := r.ReadTags(100); err != nil {
+					break
+				}
+			}
+		}
+	})
+}
+
+func TestReaderValidate(t *testing.T) {
+	// Offset of PreviousTagSize preceding the tag i.
+	offset := func(i int) int64 {
+		off := int64(9)
+		for _, it := range testTags[:i] {
+			off += 4 + 11 + int64(len(it.data))
+		}
+		return off
+	}
+	backward := append([]testTag{}, testTags...)
+	backward[5].time = 10
+	for _, it := range []struct {
+		name   string
+		in     []byte
+		modify func(b []byte)
+		off    int64
+		err    error
+	}{
+		{"valid", buildFLV(5, testTags...), nil, -1, nil},
+		{"signature", buildFLV(5, testTags...), func(b []byte) { b[0] = 'X' }, 0, ErrBadSignature},
+		{"previous size", buildFLV(5, testTags...), func(b []byte) { putUint32(b[offset(2):], 5) }, offset(2), nil},
+		{"unknown type", buildFLV(5, testTags...), func(b []byte) { b[offset(3)+4] = 7 }, offset(3), errUnknownTagType},
+		{"truncated", buildFLV(5, testTags...)[:offset(6)-2], nil, offset(5), io.ErrUnexpectedEOF},
+		{"short tag", buildFLV(5, testTags...)[:offset(5)+10], nil, offset(5), ErrShortTag},
+		{"timestamp", buildFLV(5, backward...), nil, offset(5), errTimestampOrder},
+	} {
+		if it.modify != nil {
+			it.modify(it.in)
+		}
+		err := NewReader(bytes.NewReader(it.in)).Validate()
+		if it.off < 0 {
+			if err != nil {
+				t.Errorf("%s: %v", it.name, err)
+			}
+			continue
+		}
+		var e *ValidationError
+		if !errors.As(err, &e) {
+			t.Errorf("%s: got: %v, expected validation error", it.name, err)
+			continue
+		}
+		if e.Offset != it.off {
+			t.Errorf("%s: got offset %d, expected %d", it.name, e.Offset, it.off)
+		}
+		if it.err != nil && !errors.Is(err, it.err) {
+			t.Errorf("%s: got: %v, expected: %v", it.name, err, it.err)
+		}
+	}
+}
+
+func TestReaderWalk(t *testing.T) {
+	r := NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	counts := map[uint8]int{}
+	err := r.Walk(func(tag *Tag, data io.Reader) error {
+		counts[tag.Type]++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[TagTypeAudio] != 2 || counts[TagTypeVideo] != 3 || counts[TagTypeScript] != 1 {
+		t.Errorf("got counts: %v", counts)
+	}
+
+	r = NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	n := 0
+	err = r.Walk(func(tag *Tag, data io.Reader) error {
+		if n++; tag.Time == 23 {
+			return ErrStopWalk
+		}
+		return nil
+	})
+	if err != nil || n != 4 {
+		t.Errorf("got %v after %d tags", err, n)
+	}
+	fail := errors.New("fail")
+	if err = r.Walk(func(*Tag, io.Reader) error { return fail }); err != fail {
+		t.Errorf("got: %v, expected: %v", err, fail)
+	}
+}
+
+func TestReaderDuration(t *testing.T) {
+	tags := append(testTags[:5:5], testTag{TagTypeScript, 90000, []byte{5}})
+	in := buildFLV(5, tags...)
+	broken := append([]byte{}, in...)
+	putUint32(broken[len(broken)-4:], 0x7fffffff)
+	for _, it := range []struct {
+		in []byte
+		r  func([]byte) io.Reader
+	}{
+		{in, func(b []byte) io.Reader { return bytes.NewReader(b) }},
+		{in, func(b []byte) io.Reader { return bytes.NewBuffer(b) }},
+		{broken, func(b []byte) io.Reader { return bytes.NewReader(b) }},
+	} {
+		r := NewReader(it.r(it.in))
+		r.Strict = true
+		// The filter and the statistics do not affect the scan.
+		r.SetFilter(TagTypeAudio)
+		if _, err := r.ReadHeader(); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := r.ReadTag(); err != nil {
+			t.Fatal(err)
+		}
+		stats := r.Stats()
+		d, err := r.Duration()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d != 33*time.Millisecond {
+			t.Errorf("got: %v, expected: %v", d, 33*time.Millisecond)
+		}
+		if r.Stats() != stats {
+			t.Errorf("got stats %+v after Duration, expected %+v", r.Stats(), stats)
+		}
+		if _, ok := r.s.(*bytes.Reader); ok {
+			// The position and PreviousTagSize checked by the strict reader are preserved for seekable readers.
+			if tag, _, err := r.ReadTag(); err != nil || tag.Type != TagTypeAudio || tag.Time != 23 {
+				t.Errorf("got: %v, %v, expected audio tag at 23ms", tag, err)
+			}
+		}
+		// The header is read by Duration of a fresh reader.
+		if d, err = NewReader(it.r(it.in)).Duration(); err != nil || d != 33*time.Millisecond {
+			t.Errorf("fresh reader: got: %v, %v, expected: %v", d, err, 33*time.Millisecond)
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	r.n++
+	return r.r.Read(p)
+}
+
+func TestNewReaderSize(t *testing.T) {
+	in := benchmarkFLV()
+	reads := map[int]int{}
+	for _, size := range []int{4096, 64 << 10} {
+		c := &countingReader{r: bytes.NewReader(in)}
+		r := NewReaderSize(c, size)
+		if err := r.Walk(func(tag *Tag, data io.Reader) error {
+			_, err := io.Copy(io.Discard, data)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		reads[size] = c.n
+	}
+	if reads[64<<10] >= reads[4096] {
+		t.Errorf("got reads: %v", reads)
+	}
+}
+
+func BenchmarkReaderSize(b *testing.B) {
+	in := benchmarkFLV()
+	for _, size := range []int{4096, 64 << 10} {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			b.SetBytes(int64(len(in)))
+			reads := 0
+			for i := 0; i < b.N; i++ {
+				c := &countingReader{r: bytes.NewReader(in)}
+				r := NewReaderSize(c, size)
+				r.Walk(func(tag *Tag, data io.Reader) error {
+					_, err := io.Copy(io.Discard, data)
+					return err
+				})
+				reads += c.n
+			}
+			b.ReportMetric(float64(reads)/float64(b.N), "reads/op")
+		})
+	}
+}
+
+func TestReaderMaxTagSize(t *testing.T) {
+	in := buildFLV(5, testTags[:2]...)
+	putUint24(in[13+12+len(testTags[0].data)+4:], 0xfffff0)
+	r := NewReader(bytes.NewReader(in))
+	r.MaxTagSize = 1 << 20
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.ReadTag(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.ReadTag(); !errors.Is(err, ErrTagTooLarge) {
+		t.Errorf("got: %v, expected: %v", err, ErrTagTooLarge)
+	}
+
+	// The payload of the large tag is skipped and the following tags are read.
+	large := testTag{TagTypeVideo, 40, make([]byte, 100)}
+	for _, seekable := range []bool{true, false} {
+		var in io.Reader = bytes.NewReader(buildFLV(5, testTags[0], large, testTags[1]))
+		if !seekable {
+			in = struct{ io.Reader }{in}
+		}
+		r = NewReaderSize(in, 16)
+		r.MaxTagSize = 50
+		r.Strict = true
+		if _, _, err := r.ReadTag(); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := r.ReadTag(); !errors.Is(err, ErrTagTooLarge) {
+			t.Errorf("got: %v, expected: %v", err, ErrTagTooLarge)
+		}
+		tag, data, err := r.ReadTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := io.ReadAll(data); tag.Type != testTags[1].typ || !bytes.Equal(b, testTags[1].data) {
+			t.Errorf("got: %v %x, expected: %x", tag, b, testTags[1].data)
+		}
+		if _, _, err = r.ReadTag(); err != io.EOF {
+			t.Errorf("got: %v, expected: %v", err, io.EOF)
+		}
+	}
+}
+
+func TestReaderPrevTagSize(t *testing.T) {
+	r := NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var expected uint32
+	for i := range testTags {
+		tag, _, err := r.ReadTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.PrevTagSize != expected {
+			t.Errorf("tag %d: got: %d, expected: %d", i, tag.PrevTagSize, expected)
+		}
+		expected = uint32(tag.Size) + 11
+	}
+}
+
+func TestReaderResync(t *testing.T) {
+	small := buildFLV(5, testTags...)
+	// Corrupt the size of the third tag.
+	putUint24(small[13+11+len(testTags[0].data)+4+11+len(testTags[1].data)+4+1:], 0xfffff0)
+	large := benchmarkFLV()
+	// Corrupt the size of the first audio tag following a large video tag.
+	putUint24(large[13+11+64<<10+4+1:], 0xfffff0)
+	for _, it := range []struct {
+		name     string
+		in       []byte
+		seekable bool
+		types    []uint8 // types of the first tags found
+		n        int     // expected number of recovered tags
+	}{
+		{"small", small, false, []uint8{TagTypeAudio, TagTypeVideo}, 3},
+		{"large", large, true, []uint8{TagTypeVideo, TagTypeAudio}, 198},
+		// Video tags larger than the buffer are skipped by unseekable reader.
+		{"large unseekable", large, false, []uint8{TagTypeAudio, TagTypeVideo}, 197},
+	} {
+		var in io.Reader = bytes.NewReader(it.in)
+		if !it.seekable {
+			in = struct{ io.Reader }{in}
+		}
+		r := NewReader(in)
+		r.Strict = true
+		r.MaxTagSize = 1 << 20
+		if _, err := r.ReadHeader(); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		for err == nil {
+			_, _, err = r.ReadTag()
+		}
+		if !errors.Is(err, ErrTagTooLarge) {
+			t.Fatalf("%s: got: %v, expected: %v", it.name, err, ErrTagTooLarge)
+		}
+		if err = r.Resync(); err != nil {
+			t.Fatalf("%s: %v", it.name, err)
+		}
+		n := 0
+		for ; ; n++ {
+			tag, _, err := r.ReadTag()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", it.name, err)
+			}
+			if n < len(it.types) && tag.Type != it.types[n] {
+				t.Errorf("%s: tag %d: got type %d, expected %d", it.name, n, tag.Type, it.types[n])
+			}
+		}
+		if n != it.n {
+			t.Errorf("%s: got %d tags, expected %d", it.name, n, it.n)
+		}
+	}
+	r := NewReader(bytes.NewReader(small[:60]))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Resync(); err != nil {
+		t.Fatal(err)
+	}
+	if tag, _, err := r.ReadTag(); err != nil || tag.Type != TagTypeScript {
+		t.Errorf("got: %v %v, expected: script tag", tag, err)
+	}
+	if err := r.Resync(); err != io.EOF {
+		t.Errorf("got: %v, expected: %v", err, io.EOF)
+	}
+}
+
+func TestReaderHeader(t *testing.T) {
+	in := buildFLV(FlagVideo, testTags[:2]...)
+	for _, strict := range []bool{false, true} {
+		r := NewReader(bytes.NewReader(append(in[:len(in):len(in)], buildFLV(0, testTags[2])[13:]...)))
+		r.Strict = strict
+		if r.Header() != nil {
+			t.Fatal("got header before ReadHeader")
+		}
+		h, err := r.ReadHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Header() != h || !h.HasVideo() || h.HasAudio() {
+			t.Errorf("got: %+v, expected: %+v", r.Header(), h)
+		}
+		for range testTags[:2] {
+			if _, _, err = r.ReadTag(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, _, err = r.ReadTag()
+		if strict && !errors.Is(err, errTrackFlags) {
+			t.Errorf("strict: got: %v, expected: %v", err, errTrackFlags)
+		}
+		if !strict && err != nil {
+			t.Errorf("lenient: got: %v", err)
+		}
+		r.Reset(bytes.NewReader(in))
+		if r.Header() != nil {
+			t.Error("got header after Reset")
+		}
+	}
+}
+
+func TestReaderAutoHeader(t *testing.T) {
+	r := NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	tag, data, err := r.ReadTag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := r.Header(); h == nil || h.Flags() != 5 {
+		t.Errorf("got header: %+v", h)
+	}
+	if b, _ := io.ReadAll(data); tag.Type != testTags[0].typ || !bytes.Equal(b, testTags[0].data) {
+		t.Errorf("got: %v %x, expected: %x", tag, b, testTags[0].data)
+	}
+	r = NewReader(bytes.NewReader(buildFLV(5, testTags...)[9:]))
+	if _, _, err = r.ReadTag(); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("got: %v, expected: %v", err, ErrBadSignature)
+	}
+}
+
+func TestReaderWriteTo(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	for _, n := range []int{-1, 0, 2, len(testTags)} {
+		r := NewReader(bytes.NewReader(in))
+		out := &bytes.Buffer{}
+		w := NewWriter(out)
+		if n >= 0 {
+			h, err := r.ReadHeader()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = w.WriteHeader(h); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 0; i < n; i++ {
+			tag, data, err := r.ReadTag()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Only the first payload byte is read, the rest is skipped by WriteTo.
+			b, _ := io.ReadAll(io.LimitReader(data, 1))
+			b = append(b, testTags[i].data[1:]...)
+			if err = w.WriteTag(tag, bytes.NewReader(b)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Writer appends PreviousTagSize of each tag, the reader starts with it.
+		if out.Len() > 9 {
+			out.Truncate(out.Len() - 4)
+		}
+		k, err := io.Copy(out, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), in) {
+			t.Errorf("%d tags: got: %x, expected: %x", n, out.Bytes(), in)
+		}
+		if r.Offset() != int64(len(in)) {
+			t.Errorf("%d tags: got offset %d after writing %d bytes, expected %d", n, r.Offset(), k, len(in))
+		}
+	}
+}
+
+func TestReaderRead(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	r := NewReader(bytes.NewReader(in))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.ReadTag(); err != nil {
+		t.Fatal(err)
+	}
+	// The payload of the tag is skipped.
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	off := 9 + 15 + len(testTags[0].data)
+	if !bytes.Equal(b, in[off:]) {
+		t.Errorf("got: %x, expected: %x", b, in[off:])
+	}
+	if r.Offset() != int64(len(in)) {
+		t.Errorf("got offset %d, expected %d", r.Offset(), len(in))
+	}
+}
+
+func FuzzReadTag(f *testing.F) {
+	f.Add(buildFLV(5, testTags...))
+	f.Add(buildFLV(5,
+		testTag{TagTypeScript, 0, metaDataPayload},
+		testTag{TagTypeVideo, 0, append([]byte{0x17, 0, 0, 0, 0}, testAVCConfig...)},
+		testTag{TagTypeVideo, 0, []byte{0x17, 1, 0, 0, 0, 0, 0, 0, 2, 0x65, 0x88}},
+		testTag{TagTypeVideo, 40, []byte{0x96, 0x21, 'a', 'v', 'c', '1', 0x00, 0x00, 0x00, 0x05, 0xff, 0xff, 0xfe, 0x65, 0x88}},
+		testTag{TagTypeAudio, 0, []byte{0xaf, 0, 0x12, 0x10}},
+		testTag{TagTypeAudio, 0, []byte{0x95, 0x11, 'O', 'p', 'u', 's', 0x00, 0x00, 0x00, 0x01, 0xfc}},
+	))
+	f.Fuzz(func(t *testing.T, in []byte) {
+		NewReader(bytes.NewReader(in)).Validate()
+		NewReader(bytes.NewReader(in)).Duration()
+		r := NewReader(bytes.NewReader(in))
+		r.MaxTagSize = 1 << 20
+		for {
+			tag, data, err := r.ReadTag()
+			if err != nil {
+				return
+			}
+			b, err := io.ReadAll(data)
+			if err != nil {
+				return
+			}
+			switch tag.Type & 0x1f {
+			case TagTypeAudio:
+				WalkAudioTracks(bytes.NewReader(b), func(h *AudioHeader, data io.Reader) error {
+					if h.Format == SoundFormatAAC && h.AACPacketType == AACPacketTypeSequenceHeader {
+						ParseAudioSpecificConfig(data)
+					}
+					return nil
+				})
+			case TagTypeVideo:
+				WalkVideoTracks(bytes.NewReader(b), func(h *VideoHeader, data io.Reader) error {
+					if h.Enhanced || h.CodecID != CodecIDAVC {
+						return nil
+					}
+					if h.AVCPacketType == AVCPacketTypeSequenceHeader {
+						c, err := ParseAVCDecoderConfig(data)
+						if err != nil {
+							return err
+						}
+						for _, sps := range c.SPS {
+							ParseSPSResolution(sps)
+						}
+						return nil
+					}
+					nalus, err := AVCCToAnnexB(data, r.VideoTrack().NALULengthSize())
+					if err == nil {
+						_, err = io.Copy(io.Discard, nalus)
+					}
+					return err
+				})
+			case TagTypeScript:
+				ParseMetadata(bytes.NewReader(b))
+			}
+		}
+	})
+}
+
+type countingSeeker struct {
+	countingReader
+	s     io.Seeker
+	seeks int
+}
+
+func (s *countingSeeker) Seek(off int64, whence int) (int64, error) {
+	s.seeks++
+	return s.s.Seek(off, whence)
+}
+
+func TestReaderSeekThreshold(t *testing.T) {
+	in := benchmarkFLV()
+	for _, it := range []struct {
+		threshold int64
+		seeks     int
+	}{
+		{0, 100},
+		{64 << 10, 100},
+		{64<<10 + 1, 0},
+	} {
+		b := bytes.NewReader(in)
+		c := &countingSeeker{countingReader: countingReader{r: b}, s: b}
+		r := NewReader(c)
+		r.SeekThreshold = it.threshold
+		tags := 0
+		if err := r.Walk(func(tag *Tag, data io.Reader) error {
+			tags++
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if tags != 200 {
+			t.Errorf("threshold %d: got %d tags, expected %d", it.threshold, tags, 200)
+		}
+		if c.seeks != it.seeks {
+			t.Errorf("threshold %d: got %d seeks, expected %d", it.threshold, c.seeks, it.seeks)
+		}
+		if it.seeks == 0 && c.n < len(in)/readBufferSize {
+			t.Errorf("threshold %d: got %d reads, expected at least %d", it.threshold, c.n, len(in)/readBufferSize)
+		}
+	}
+}
+
+func TestPayloadReaderRemaining(t *testing.T) {
+	r := NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	for _, it := range testTags {
+		_, data, err := r.ReadTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := data.(*PayloadReader)
+		half := len(it.data) / 2
+		if _, err = io.ReadFull(p, make([]byte, half)); err != nil {
+			t.Fatal(err)
+		}
+		if n := p.Remaining(); n != int64(len(it.data)-half) {
+			t.Errorf("got %d remaining bytes, expected %d", n, len(it.data)-half)
+		}
+	}
+}
+
+func TestReaderFindSignature(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	for _, it := range []struct {
+		junk    int
+		maxScan int
+		err     error
+	}{
+		{0, 0, nil},
+		{5, 5, nil},
+		{5, 4, ErrNoSignature},
+		{5000, 5000, nil},
+		{5000, 10000, nil},
+		{5000, 4999, ErrNoSignature},
+	} {
+		junk := bytes.Repeat([]byte("FL"), it.junk/2+1)[:it.junk]
+		r := NewReader(bytes.NewReader(append(junk, in...)))
+		err := r.FindSignature(it.maxScan)
+		if !errors.Is(err, it.err) {
+			t.Errorf("junk %d, max %d: got: %v, expected: %v", it.junk, it.maxScan, err, it.err)
+		}
+		if err != nil {
+			continue
+		}
+		if _, err = r.ReadHeader(); err != nil {
+			t.Fatal(err)
+		}
+		if r.Offset() != int64(it.junk+9) {
+			t.Errorf("got offset %d, expected %d", r.Offset(), it.junk+9)
+		}
+		tag, _, err := r.ReadTag()
+		if err != nil || tag.Type != testTags[0].typ {
+			t.Errorf("got: %v, %v, expected script tag", tag, err)
+		}
+	}
+	r := NewReader(bytes.NewReader([]byte("junk")))
+	if err := r.FindSignature(100); !errors.Is(err, ErrNoSignature) {
+		t.Errorf("got: %v, expected: %v", err, ErrNoSignature)
+	}
+}
+
+func TestReaderEncrypted(t *testing.T) {
+	in := buildFLV(5,
+		testTag{TagTypeScript, 0, append([]byte("\x02\x00\x11|AdditionalHeader"), 8, 0, 0, 0, 0, 0, 0, 9)},
+		testTag{TagTypeVideo | TagFilter, 0, []byte{0x17, 0, 0, 0, 0, 1, 2, 3}},
+		testTags[2],
+	)
+	r := NewReader(bytes.NewReader(in))
+	h, err := r.ReadHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Encrypted {
+		t.Error("header is encrypted before reading tags")
+	}
+	if _, _, err = r.ReadTag(); err != nil {
+		t.Fatal(err)
+	}
+	if !h.Encrypted {
+		t.Error("header is not encrypted after the additional header")
+	}
+	if _, _, err = r.ReadTag(); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("got: %v, expected: %v", err, ErrEncrypted)
+	}
+	if tag, _, err := r.ReadTag(); err != nil || tag.Type != TagTypeAudio {
+		t.Errorf("got: %v, %v, expected audio tag", tag, err)
+	}
+
+	r = NewReader(bytes.NewReader(buildFLV(5, testTag{TagTypeAudio | TagFilter, 0, []byte{0xaf, 1, 2}})))
+	if _, _, err = r.ReadTag(); !errors.Is(err, ErrEncrypted) || !r.Header().Encrypted {
+		t.Errorf("got: %v, encrypted %v, expected: %v", err, r.Header().Encrypted, ErrEncrypted)
+	}
+	r = NewReader(bytes.NewReader(buildFLV(5, testTags...)))
+	if err = r.Walk(func(*Tag, io.Reader) error { return nil }); err != nil || r.Header().Encrypted {
+		t.Errorf("got: %v, encrypted %v", err, r.Header().Encrypted)
+	}
+}
+
+func TestReaderMaxScanBytes(t *testing.T) {
+	in := benchmarkFLV()
+	// A script tag at the end makes the backward scan skip a script tag larger than the limit.
+	tail := buildFLV(5, testTag{TagTypeScript, 0, make([]byte, 2<<20)})[13:]
+	for _, it := range []struct {
+		name string
+		scan func(r *Reader) error
+	}{
+		{"validate", func(r *Reader) error { return r.Validate() }},
+		{"duration", func(r *Reader) error {
+			if _, err := r.ReadHeader(); err != nil {
+				return err
+			}
+			_, err := r.Duration()
+			return err
+		}},
+		{"backward", func(r *Reader) error {
+			br := NewReader(bytes.NewReader(append(in[:len(in):len(in)], tail...)))
+			br.MaxScanBytes = r.MaxScanBytes
+			if _, err := br.ReadHeader(); err != nil {
+				return err
+			}
+			_, err := br.Duration()
+			return err
+		}},
+	} {
+		for _, limit := range []int64{0, 1 << 20} {
+			r := NewReader(&countingReader{r: bytes.NewReader(in)})
+			r.MaxScanBytes = limit
+			err := it.scan(r)
+			if limit == 0 && err != nil {
+				t.Errorf("%s: %v", it.name, err)
+			}
+			if limit > 0 && !errors.Is(err, ErrScanLimitExceeded) {
+				t.Errorf("%s: got: %v, expected: %v", it.name, err, ErrScanLimitExceeded)
+			}
+		}
+	}
+}
+
+func TestReaderStreamID(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := NewWriter(out)
+	if err := w.WriteHeader(NewHeader(true, true)); err != nil {
+		t.Fatal(err)
+	}
+	for _, stream := range []uint32{0, 1, 0xabcdef} {
+		if err := w.WriteTag(&Tag{Type: TagTypeAudio, Stream: stream}, bytes.NewReader([]byte{0xaf, 1})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := NewReader(bytes.NewReader(out.Bytes()))
+	for _, stream := range []uint32{0, 1, 0xabcdef} {
+		tag, _, err := r.ReadTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Stream != stream {
+			t.Errorf("got stream %d, expected %d", tag.Stream, stream)
+		}
+	}
+	r = NewReader(bytes.NewReader(out.Bytes()))
+	r.Strict = true
+	if _, _, err := r.ReadTag(); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, _, err := r.ReadTag(); !errors.Is(err, errStreamID) {
+			t.Errorf("got: %v, expected: %v", err, errStreamID)
+		}
+	}
+	if _, _, err := r.ReadTag(); err != io.EOF {
+		t.Errorf("got: %v, expected: %v", err, io.EOF)
+	}
+}
+
+func TestReaderMissingFinalPrevTagSize(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	for _, cut := range []int{0, 2, 4} {
+		for _, strict := range []bool{false, true} {
+			r := NewReader(bytes.NewReader(in[:len(in)-cut]))
+			r.Strict = strict
+			n := 0
+			err := r.Walk(func(*Tag, io.Reader) error {
+				n++
+				return nil
+			})
+			if n != len(testTags) {
+				t.Errorf("cut %d, strict %v: got %d tags, expected %d", cut, strict, n, len(testTags))
+			}
+			if strict && cut > 0 {
+				if !errors.Is(err, ErrShortTag) {
+					t.Errorf("cut %d, strict: got: %v, expected: %v", cut, err, ErrShortTag)
+				}
+			} else if err != nil {
+				t.Errorf("cut %d, strict %v: %v", cut, strict, err)
+			}
+		}
+	}
+}
+
+func TestReaderPeekTagType(t *testing.T) {
+	for _, in := range [][]byte{buildFLV(5, testTags...), benchmarkFLV()} {
+		r := NewReader(bytes.NewReader(in))
+		typ, err := r.PeekTagType()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			tag, data, err := r.ReadTag()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tag.Type != typ {
+				t.Fatalf("got type %d, expected %d", tag.Type, typ)
+			}
+			next, err := r.PeekTagType()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b, err := io.ReadAll(data); tag.Size+4 < readBufferSize && (err != nil || len(b) != tag.Size) {
+				t.Errorf("%s: got %d bytes, %v, expected %d bytes", tag, len(b), err, tag.Size)
+			}
+			typ = next
+		}
+		if _, err = r.PeekTagType(); err != io.EOF {
+			t.Errorf("got: %v, expected: %v", err, io.EOF)
+		}
+	}
+}
+
+func TestReaderUnknownTag(t *testing.T) {
+	in := buildFLV(5, testTags[1], testTag{0x0a, 10, []byte{1, 2, 3}}, testTags[2])
+	for _, it := range []struct {
+		strict  bool
+		custom  []uint8
+		filter  []uint8
+		types   []uint8
+		unknown int
+		err     error
+	}{
+		{false, nil, nil, []uint8{TagTypeVideo, 0x0a, TagTypeAudio}, 1, nil},
+		{true, nil, nil, []uint8{TagTypeVideo}, 0, errUnknownTagType},
+		{true, []uint8{0x0a}, nil, []uint8{TagTypeVideo, 0x0a, TagTypeAudio}, 0, nil},
+		{false, nil, []uint8{0x0a}, []uint8{0x0a}, 1, nil},
+		{false, nil, []uint8{TagTypeAudio}, []uint8{TagTypeAudio}, 0, nil},
+	} {
+		r := NewReader(bytes.NewReader(in))
+		r.Strict = it.strict
+		r.CustomTagTypes = it.custom
+		r.SetFilter(it.filter...)
+		unknown := 0
+		r.OnUnknownTag = func(tag *Tag) {
+			if tag.Type != 0x0a || tag.Size != 3 {
+				t.Errorf("got unknown tag %+v", tag)
+			}
+			unknown++
+		}
+		var types []uint8
+		err := r.Walk(func(tag *Tag, _ io.Reader) error {
+			types = append(types, tag.Type)
+			return nil
+		})
+		if !errors.Is(err, it.err) {
+			t.Errorf("got: %v, expected: %v", err, it.err)
+		}
+		if !bytes.Equal(types, it.types) || unknown != it.unknown {
+			t.Errorf("got types %v, %d unknown, expected %v, %d unknown", types, unknown, it.types, it.unknown)
+		}
+	}
+	// The unknown tag is skipped by the strict reader after the error.
+	r := NewReader(bytes.NewReader(in))
+	r.Strict = true
+	for _, expected := range []error{nil, errUnknownTagType} {
+		if _, _, err := r.ReadTag(); !errors.Is(err, expected) {
+			t.Errorf("got: %v, expected: %v", err, expected)
+		}
+	}
+	if tag, _, err := r.ReadTag(); err != nil || tag.Type != TagTypeAudio {
+		t.Errorf("got: %v, %v, expected audio tag", tag, err)
+	}
+}
+
+func TestReaderUnwrapTimestamps(t *testing.T) {
+	type wrap struct{ raw, abs int64 }
+	for _, stream := range [][]wrap{
+		// The 24-bit field wraps without the extended byte.
+		{{1<<24 - 100, 1<<24 - 100}, {1<<24 - 60, 1<<24 - 60}, {1<<24 - 80, 1<<24 - 80}, {20, 1<<24 + 20}, {0, 1 << 24}, {5000, 1<<24 + 5000}},
+		// The whole 32-bit timestamp wraps.
+		{{1<<32 - 40, 1<<32 - 40}, {2, 1<<32 + 2}, {1 << 24, 1<<32 + 1<<24}},
+		// Resets after reconnection are not wraparounds.
+		{{3 * 3600000, 3 * 3600000}, {0, 0}, {40, 40}, {1<<24 - 20000, 1<<24 - 20000}, {10, 10}},
+	} {
+		var tags []testTag
+		var expected []int64
+		for i, it := range stream {
+			typ := TagTypeVideo
+			if i%2 == 0 {
+				typ = TagTypeAudio
+			}
+			tags = append(tags, testTag{typ, it.raw, []byte{0}})
+			expected = append(expected, it.abs)
+		}
+		in := buildFLV(5, tags...)
+		for _, unwrap := range []bool{false, true} {
+			r := NewReader(bytes.NewReader(in))
+			r.UnwrapTimestamps = unwrap
+			var got, raw []int64
+			if err := r.Walk(func(tag *Tag, _ io.Reader) error {
+				got = append(got, tag.AbsoluteTime)
+				raw = append(raw, tag.Time)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			want := expected
+			if !unwrap {
+				want = raw
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("unwrap %v: got: %v, expected: %v", unwrap, got, want)
+			}
+			if raw[len(raw)-1] != stream[len(stream)-1].raw {
+				t.Errorf("got raw time %d, expected %d", raw[len(raw)-1], stream[len(stream)-1].raw)
+			}
+		}
+	}
+}
+
+// growingFile is a file appended concurrently with reading.
+type growingFile struct {
+	mu  sync.Mutex
+	b   []byte
+	pos int
+}
+
+func (f *growingFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pos >= len(f.b) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.b[f.pos:])
+	f.pos += n
+	return n, nil
+}
+
+func (f *growingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.b = append(f.b, p...)
+	return len(p), nil
+}
+
+func TestTailReader(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	f := &growingFile{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		// Tags are split between writes.
+		for b := in; len(b) > 0; {
+			n := min(len(b), 7)
+			f.Write(b[:n])
+			b = b[n:]
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	r := NewTailReader(ctx, f, time.Millisecond)
+	for i, it := range testTags {
+		tag, data, err := r.ReadTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Type != it.typ || tag.Time != it.time || !bytes.Equal(b, it.data) {
+			t.Errorf("tag %d: got: %v %x, expected: %x", i, tag, b, it.data)
+		}
+	}
+	done := make(chan error)
+	go func() {
+		_, _, err := r.ReadTag()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("got: %v at the end of the stream, expected to wait", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got: %v, expected: %v", err, context.Canceled)
+	}
+}
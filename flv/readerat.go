@@ -0,0 +1,65 @@
+package flv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotTagBoundary is returned by ReadTagAt if the offset does not point to a tag.
+var ErrNotTagBoundary = errors.New("flv: offset is not at tag boundary")
+
+// ReaderAt reads FLV tags at arbitrary offsets of a random access source.
+// Unlike Reader it does not keep a read position, so it is safe for concurrent use.
+type ReaderAt struct {
+	r    io.ReaderAt
+	size int64
+}
+
+// NewReaderAt returns a new reader that reads from ra of the given size in bytes.
+func NewReaderAt(ra io.ReaderAt, size int64) *ReaderAt {
+	return &ReaderAt{r: ra, size: size}
+}
+
+// ReadTagAt reads the tag header at offset off and returns the payload reader.
+// The offset points to the tag header, the next tag header follows at off+Size+15.
+// The tag is checked against the following PreviousTagSize field unless it is the last one,
+// ErrNotTagBoundary is returned if the check fails.
+func (r *ReaderAt) ReadTagAt(off int64) (*Tag, io.Reader, error) {
+	if off < 13 || off+11 > r.size {
+		return nil, nil, fmt.Errorf("%w: %d", ErrNotTagBoundary, off)
+	}
+	var b [11]byte
+	if err := readFullAt(r.r, b[:], off); err != nil {
+		return nil, nil, err
+	}
+	tag := &Tag{}
+	tag.parseHeader(b[:])
+	tag.AbsoluteTime = tag.Time
+	end := off + 11 + int64(tag.Size)
+	if t := tag.Type & 0x1f; t != TagTypeAudio && t != TagTypeVideo && t != TagTypeScript || end > r.size {
+		return nil, nil, fmt.Errorf("%w: %d", ErrNotTagBoundary, off)
+	}
+	if end+4 <= r.size {
+		if err := readFullAt(r.r, b[:4], end); err != nil {
+			return nil, nil, err
+		}
+		if int(getUint32(b[:])) != tag.Size+11 {
+			return nil, nil, fmt.Errorf("%w: %d", ErrNotTagBoundary, off)
+		}
+	}
+	return tag, io.NewSectionReader(r.r, off+11, int64(tag.Size)), nil
+}
+
+// readFullAt reads len(b) bytes at offset off. Unlike io.ReadFull, it succeeds if all bytes are read
+// even with io.EOF, which ReadAt may return when the read ends at the end of the input.
+func readFullAt(ra io.ReaderAt, b []byte, off int64) error {
+	n, err := ra.ReadAt(b, off)
+	if n == len(b) {
+		return nil
+	}
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return unexpectedEOF(err)
+}
@@ -0,0 +1,75 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// eofReaderAt returns io.EOF with the bytes of the read ending at the end of input, as ReadAt is allowed to.
+type eofReaderAt struct {
+	*bytes.Reader
+}
+
+func (r eofReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	n, err := r.Reader.ReadAt(b, off)
+	if err == nil && off+int64(n) == r.Size() {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func TestReaderAt(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	r := NewReaderAt(bytes.NewReader(in), int64(len(in)))
+	off := int64(13)
+	var offsets []int64
+	for i, it := range testTags {
+		tag, data, err := r.ReadTagAt(off)
+		if err != nil {
+			t.Fatalf("tag %d: %v", i, err)
+		}
+		if tag.Type != it.typ || tag.Time != it.time || tag.Size != len(it.data) {
+			t.Errorf("tag %d: got: %+v", i, tag)
+		}
+		if b, _ := io.ReadAll(data); !bytes.Equal(b, it.data) {
+			t.Errorf("tag %d: got payload: %x, expected: %x", i, b, it.data)
+		}
+		offsets = append(offsets, off)
+		off += int64(tag.Size) + 15
+	}
+	for _, off := range []int64{0, 9, 14, offsets[2] + 1, offsets[5] - 4, int64(len(in))} {
+		if _, _, err := r.ReadTagAt(off); !errors.Is(err, ErrNotTagBoundary) {
+			t.Errorf("offset %d: got: %v, expected: %v", off, err, ErrNotTagBoundary)
+		}
+	}
+
+	// The last tag is read from the input returning io.EOF with its final PreviousTagSize.
+	eof := NewReaderAt(eofReaderAt{bytes.NewReader(in)}, int64(len(in)))
+	if tag, _, err := eof.ReadTagAt(offsets[5]); err != nil || tag.Time != testTags[5].time {
+		t.Errorf("got: %v, %v, expected the last tag", tag, err)
+	}
+
+	var wg sync.WaitGroup
+	payloads := make([][]byte, 2)
+	for i := range payloads {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, data, err := r.ReadTagAt(offsets[4])
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			payloads[i], _ = io.ReadAll(data)
+		}(i)
+	}
+	wg.Wait()
+	for _, b := range payloads {
+		if !bytes.Equal(b, testTags[4].data) {
+			t.Errorf("got payload: %x, expected: %x", b, testTags[4].data)
+		}
+	}
+}
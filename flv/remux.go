@@ -0,0 +1,517 @@
+package flv
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// Concat writes a single FLV stream with the header of the first input, followed by tags of all inputs in order.
+// Timestamps of each following input are shifted to continue after the last timestamp of the previous one
+// by the last frame interval of the track of its last audio or video tag, or by 1 ms if it is unknown.
+// onMetaData script tags of the following inputs are dropped, as well as the sequence headers
+// equal to the previous ones. Differing sequence headers are kept, so players can reconfigure decoders.
+// Use ConcatFunc to be warned about them.
+func Concat(w io.Writer, readers ...io.Reader) error {
+	return ConcatFunc(w, nil, readers...)
+}
+
+// ConcatFunc is like Concat but calls onConfigChange, if it is not nil, for every audio or video sequence header
+// differing from the previous one of the same type, with the index of its input and its header.
+// Such changes are usually caused by inputs encoded with different settings, which some players fail to decode.
+// The tag timestamp is not shifted yet and the tag is valid only during the call.
+func ConcatFunc(w io.Writer, onConfigChange func(input int, tag *Tag), readers ...io.Reader) error {
+	fw := NewWriter(w)
+	var configs [2][]byte // the last video and audio sequence headers
+	var buf bytes.Buffer
+	var tag Tag
+	var last, step [2]int64 // the last timestamp and frame interval of the video and audio tracks
+	track := -1             // the track of the last audio or video tag written
+	for i, in := range readers {
+		last = [2]int64{-1, -1}
+		r := NewReader(in)
+		h, err := r.ReadHeader()
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			if err = fw.WriteHeader(h); err != nil {
+				return err
+			}
+		}
+		first := true
+		for {
+			data, err := r.ReadTagInto(&tag)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			buf.Reset()
+			if _, err = buf.ReadFrom(data); err != nil {
+				return err
+			}
+			b := buf.Bytes()
+			if i > 0 && first {
+				gap := int64(1)
+				if track >= 0 {
+					gap = max(step[track], 1)
+				}
+				fw.SetTimeOffset(fw.LastTime() - tag.Time + gap)
+				first = false
+			}
+			if k := sequenceHeader(&tag, b); k >= 0 {
+				if i > 0 && bytes.Equal(b, configs[k]) {
+					continue
+				}
+				if configs[k] != nil && onConfigChange != nil && !bytes.Equal(b, configs[k]) {
+					onConfigChange(i, &tag)
+				}
+				configs[k] = append(configs[k][:0], b...)
+			} else if i > 0 && tag.Type == TagTypeScript && isMetadata(b) {
+				continue
+			}
+			if err = fw.WriteTag(&tag, bytes.NewReader(b)); err != nil {
+				return err
+			}
+			k := -1
+			switch tag.Type & 0x1f {
+			case TagTypeVideo:
+				k = 0
+			case TagTypeAudio:
+				k = 1
+			}
+			if k >= 0 {
+				if last[k] >= 0 && tag.Time > last[k] {
+					step[k] = tag.Time - last[k]
+				}
+				last[k], track = tag.Time, k
+			}
+		}
+	}
+	return nil
+}
+
+// Remux copies the header and tags from r to w, dropping script tags if dropScript is true.
+// PreviousTagSize fields are written anew, so they are correct regardless of the dropped tags.
+func Remux(r io.Reader, w io.Writer, dropScript bool) error {
+	fr := NewReader(r)
+	fw := NewWriter(w)
+	h, err := fr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	if err = fw.WriteHeader(h); err != nil {
+		return err
+	}
+	if dropScript {
+		fr.SetFilter(TagTypeAudio, TagTypeVideo)
+	}
+	var tag Tag
+	for {
+		data, err := fr.ReadTagInto(&tag)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fw.WriteTag(&tag, data); err != nil {
+			return err
+		}
+	}
+}
+
+// resetThreshold is the backward timestamp jump in milliseconds considered a reset by NormalizeTimestamps.
+// Smaller jumps are common for interleaved audio and video and are kept.
+const resetThreshold = 1000
+
+// NormalizeTimestamps copies the header and tags from r to w, keeping timestamps increasing
+// if they reset, for example after reconnection of a live stream.
+// Whenever a timestamp goes back by more than a second, the offset added to the following timestamps
+// is increased by the greatest timestamp seen before the reset.
+func NormalizeTimestamps(r io.Reader, w io.Writer) error {
+	fr := NewReader(r)
+	fw := NewWriter(w)
+	h, err := fr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	if err = fw.WriteHeader(h); err != nil {
+		return err
+	}
+	var tag Tag
+	var offset, last int64
+	for {
+		data, err := fr.ReadTagInto(&tag)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if tag.Time+resetThreshold < last {
+			offset += last
+			fw.SetTimeOffset(offset)
+			last = tag.Time
+		} else if tag.Time > last {
+			last = tag.Time
+		}
+		if err = fw.WriteTag(&tag, data); err != nil {
+			return err
+		}
+	}
+}
+
+// InjectMetadata copies the header and tags from r to w, replacing onMetaData script tags
+// with a single one encoded from m at timestamp 0.
+// If r implements io.Seeker, the tags are scanned beforehand and FileSize and LastTimestamp
+// of the written metadata are set from the output size and the last media timestamp, m is not modified.
+func InjectMetadata(r io.Reader, w io.Writer, m *Metadata) error {
+	meta := *m
+	if s, ok := r.(io.ReadSeeker); ok {
+		if err := meta.scan(s); err != nil {
+			return err
+		}
+	}
+	fr := NewReader(r)
+	fw := NewWriter(w)
+	h, err := fr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	if err = fw.WriteHeader(h); err != nil {
+		return err
+	}
+	if err = fw.WriteMetadata(&meta); err != nil {
+		return err
+	}
+	return copyTags(fr, fw)
+}
+
+// copyTags copies the remaining tags except onMetaData script tags.
+func copyTags(r *Reader, w *Writer) error {
+	var buf bytes.Buffer
+	var tag Tag
+	for {
+		data, err := r.ReadTagInto(&tag)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, meta, err := metadataTag(&tag, data, &buf)
+		if err != nil {
+			return err
+		}
+		if meta {
+			continue
+		}
+		if err = w.WriteTag(&tag, data); err != nil {
+			return err
+		}
+	}
+}
+
+// scan sets FileSize and LastTimestamp of the stream s with the metadata injected.
+// The position of s is restored.
+func (m *Metadata) scan(s io.ReadSeeker) error {
+	pos, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	size, last, err := scanTags(s)
+	if err != nil {
+		return err
+	}
+	m.LastTimestamp = float64(last) / 1000
+	if size, err = m.fileSize(size); err != nil {
+		return err
+	}
+	m.FileSize = float64(size)
+	_, err = s.Seek(pos, io.SeekStart)
+	return err
+}
+
+// ComputeFilesize reads the FLV stream r and returns the size of the output of InjectMetadata, copying r
+// without seeking, with m having FileSize set to the result. The size of the encoded metadata depends on FileSize,
+// so it is computed repeatedly until it is stable. FileSize of m is not modified.
+func ComputeFilesize(r io.Reader, m *Metadata) (int64, error) {
+	size, _, err := scanTags(r)
+	if err != nil {
+		return 0, err
+	}
+	return m.fileSize(size)
+}
+
+// fileSize returns the size of the stream of the given size without metadata, with the metadata injected.
+func (m *Metadata) fileSize(size int64) (int64, error) {
+	meta := *m
+	total := size
+	for {
+		// Numbers are encoded in fixed size, so the size is stable once FileSize is encoded.
+		meta.FileSize = float64(total)
+		b, err := meta.encode()
+		if err != nil {
+			return 0, err
+		}
+		n := size + int64(len(b)) + 15
+		if n == total {
+			return total, nil
+		}
+		total = n
+	}
+}
+
+// scanTags returns the size of the FLV stream r without onMetaData script tags and the last media timestamp.
+func scanTags(r io.Reader) (size, last int64, err error) {
+	fr := NewReader(r)
+	h, err := fr.ReadHeader()
+	if err != nil {
+		return 0, 0, err
+	}
+	size = int64(13 + len(h.Extra))
+	var buf bytes.Buffer
+	err = fr.Walk(func(tag *Tag, data io.Reader) error {
+		_, meta, err := metadataTag(tag, data, &buf)
+		if err != nil || meta {
+			return err
+		}
+		if t := tag.Type & 0x1f; (t == TagTypeAudio || t == TagTypeVideo) && tag.Time > last {
+			last = tag.Time
+		}
+		size += int64(tag.Size) + 15
+		return nil
+	})
+	return size, last, err
+}
+
+// metadataTag reports whether the tag is onMetaData script tag, which is replaced by InjectMetadata,
+// ignoring filter and reserved bits of the tag type. The payload of script tags is read into buf,
+// the returned reader reads the payload of any tag.
+func metadataTag(tag *Tag, data io.Reader, buf *bytes.Buffer) (io.Reader, bool, error) {
+	if tag.Type&0x1f != TagTypeScript {
+		return data, false, nil
+	}
+	buf.Reset()
+	if _, err := buf.ReadFrom(data); err != nil {
+		return nil, false, err
+	}
+	return buf, isMetadata(buf.Bytes()), nil
+}
+
+// sequenceHeader returns 0 for video and 1 for audio sequence header tags with payload b, otherwise -1.
+func sequenceHeader(tag *Tag, b []byte) int {
+	if len(b) < 2 {
+		return -1
+	}
+	switch tag.Type {
+	case TagTypeVideo:
+		if b[0]&0x80 != 0 {
+			if b[0]&0xf == PacketTypeSequenceStart && b[0]>>4&7 != FrameTypeInfo {
+				return 0
+			}
+		} else if b[0]&0xf == CodecIDAVC && b[1] == AVCPacketTypeSequenceHeader {
+			return 0
+		}
+	case TagTypeAudio:
+		switch SoundFormat(b[0] >> 4) {
+		case SoundFormatAAC:
+			if b[1] == AACPacketTypeSequenceHeader {
+				return 1
+			}
+		case SoundFormatExHeader:
+			if b[0]&0xf == AudioPacketTypeSequenceStart {
+				return 1
+			}
+		}
+	}
+	return -1
+}
+
+// isMetadata reports whether the script tag payload b is onMetaData.
+func isMetadata(b []byte) bool {
+	name, err := DecodeAMF0(bytes.NewReader(b))
+	return err == nil && name == "onMetaData"
+}
+
+// Split reads the header unless it is already read and writes a new FLV stream to w
+// with the tags in time range [start, end) rebased to zero.
+// The output starts at the first video keyframe at or after start, or at the first tag at or after start
+// if no video tags precede it. It is prepended with onMetaData having the duration adjusted
+// and the last audio and video sequence headers, so it is decodable on its own.
+// Reading stops at the first tag at or after end.
+func (r *Reader) Split(w io.Writer, start, end time.Duration) error {
+	if r.data == 0 {
+		if _, err := r.ReadHeader(); err != nil {
+			return err
+		}
+	}
+	first, last := start.Milliseconds(), end.Milliseconds()
+	fw := NewWriter(w)
+	var configs [2][]byte
+	var meta *Metadata
+	var buf bytes.Buffer
+	var tag Tag
+	video, begun := false, false
+	var origin int64
+	for {
+		data, err := r.ReadTagInto(&tag)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if tag.Time >= last {
+			break
+		}
+		buf.Reset()
+		if _, err = buf.ReadFrom(data); err != nil {
+			return err
+		}
+		b := buf.Bytes()
+		k := sequenceHeader(&tag, b)
+		if !begun {
+			switch {
+			case k >= 0:
+				configs[k] = append(configs[k][:0], b...)
+				video = video || k == 0
+				continue
+			case tag.Type == TagTypeScript && isMetadata(b):
+				if meta, err = ParseMetadata(bytes.NewReader(b)); err != nil {
+					return err
+				}
+				continue
+			case tag.Type == TagTypeVideo:
+				video = true
+				if tag.Time < first || len(b) == 0 || b[0]>>4&7 != FrameTypeKey {
+					continue
+				}
+			case tag.Time < first || video:
+				continue
+			}
+			if err = r.beginSplit(fw, meta, configs, tag.Time, last); err != nil {
+				return err
+			}
+			begun, origin = true, tag.Time
+		} else if tag.Time < origin {
+			// Interleaved tag preceding the keyframe.
+			continue
+		}
+		if err = fw.WriteTag(&tag, bytes.NewReader(b)); err != nil {
+			return err
+		}
+	}
+	if !begun {
+		return fw.WriteHeader(r.splitHeader())
+	}
+	return nil
+}
+
+// beginSplit writes the header, metadata and sequence headers of the stream split at ms
+// and rebases the following tags.
+func (r *Reader) beginSplit(w *Writer, meta *Metadata, configs [2][]byte, ms, end int64) error {
+	if err := w.WriteHeader(r.splitHeader()); err != nil {
+		return err
+	}
+	if meta != nil {
+		m := *meta
+		if d := float64(end) / 1000; m.Duration > d {
+			m.Duration = d
+		}
+		m.Duration -= float64(ms) / 1000
+		if m.Duration < 0 {
+			m.Duration = 0
+		}
+		m.FileSize, m.Keyframes = 0, nil
+		if err := w.WriteMetadata(&m); err != nil {
+			return err
+		}
+	}
+	for i, typ := range []uint8{TagTypeVideo, TagTypeAudio} {
+		if configs[i] != nil {
+			if err := w.WriteTag(&Tag{Type: typ}, bytes.NewReader(configs[i])); err != nil {
+				return err
+			}
+		}
+	}
+	w.SetTimeOffset(-ms)
+	return nil
+}
+
+func (r *Reader) splitHeader() *Header {
+	if r.head == nil {
+		return NewHeader(true, true)
+	}
+	return &Header{flags: r.head.flags}
+}
+
+// gopInterval is the duration in milliseconds of the groups of tags GOPs makes for streams without video.
+const gopInterval = 2000
+
+// GOPs reads the header unless it is already read and the remaining tags, and calls fn for each group of tags
+// starting at a video keyframe and including the audio and script tags interleaved until the next keyframe.
+// Tags preceding the first keyframe make a group of their own. Streams without video are grouped by 2 seconds.
+// Every group starts with the last audio and video sequence headers read before it, timestamped as its first tag,
+// so it is decodable on its own, sequence headers are not passed otherwise. Their payloads are shared by the groups
+// and must not be modified. If fn returns ErrStopWalk, GOPs stops and returns nil.
+func (r *Reader) GOPs(fn func(gop []TagWithPayload) error) error {
+	var configs [2]*TagWithPayload
+	var gop []TagWithPayload
+	video := false
+	var start int64
+	emit := func() error {
+		if len(gop) == 0 {
+			return nil
+		}
+		err := fn(gop)
+		gop = nil
+		return err
+	}
+	for {
+		t, err := r.ReadTagWithPayload()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if k := sequenceHeader(t.Tag, t.Data); k >= 0 {
+			configs[k] = t
+			continue
+		}
+		key := false
+		if t.Tag.Type == TagTypeVideo {
+			video = true
+			if h, _, err := ParseVideoHeader(bytes.NewReader(t.Data)); err == nil {
+				key = h.IsKeyframe() && h.isFrame()
+			}
+		}
+		if key || !video && len(gop) > 0 && t.Tag.Time-start >= gopInterval {
+			if err = emit(); err != nil {
+				if err == ErrStopWalk {
+					return nil
+				}
+				return err
+			}
+		}
+		if len(gop) == 0 {
+			start = t.Tag.Time
+			for _, c := range configs {
+				if c != nil {
+					tag := *c.Tag
+					tag.Time = start
+					gop = append(gop, TagWithPayload{&tag, c.Data})
+				}
+			}
+		}
+		gop = append(gop, *t)
+	}
+	if err := emit(); err != ErrStopWalk {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package flv
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConcat(t *testing.T) {
+	aac := testTag{TagTypeAudio, 0, []byte{0xaf, 0, 0x12, 0x10}}
+	first := buildFLV(5, testTags[:5]...)
+	second := buildFLV(5,
+		testTags[0], testTags[1], aac,
+		testTag{TagTypeAudio, 0, []byte{0xaf, 1, 1}},
+		testTag{TagTypeVideo, 40, []byte{0x27, 1, 0, 0, 0, 1}},
+		// Changed configuration.
+		testTag{TagTypeAudio, 60, []byte{0xaf, 0, 0x11, 0x90}},
+	)
+	out := &bytes.Buffer{}
+	var changes [][3]int64
+	onConfigChange := func(input int, tag *Tag) {
+		changes = append(changes, [3]int64{int64(input), int64(tag.Type), tag.Time})
+	}
+	if err := ConcatFunc(out, onConfigChange, bytes.NewReader(first), bytes.NewReader(second)); err != nil {
+		t.Fatal(err)
+	}
+	// Only the changed audio configuration of the second input is reported.
+	if expected := [][3]int64{{1, int64(TagTypeAudio), 60}}; !reflect.DeepEqual(changes, expected) {
+		t.Errorf("got config changes %v, expected %v", changes, expected)
+	}
+	expected := append(testTags[:5:5],
+		// The second input follows the last video tag by its frame interval.
+		testTag{TagTypeAudio, 66, []byte{0xaf, 1, 1}},
+		testTag{TagTypeVideo, 106, []byte{0x27, 1, 0, 0, 0, 1}},
+		testTag{TagTypeAudio, 126, []byte{0xaf, 0, 0x11, 0x90}},
+	)
+	if in := buildFLV(5, expected...); !bytes.Equal(out.Bytes(), in) {
+		t.Errorf("got: %x, expected: %x", out.Bytes(), in)
+	}
+	r := NewReader(bytes.NewReader(out.Bytes()))
+	r.Strict = true
+	if err := r.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	// Timestamps are strictly increasing across the splice, by 1 ms without a frame interval.
+	for _, it := range []struct {
+		in       []testTag
+		expected []int64
+	}{
+		{[]testTag{{TagTypeVideo, 0, []byte{0x27, 1}}, {TagTypeVideo, 40, []byte{0x27, 1}}}, []int64{0, 40, 80, 120}},
+		{[]testTag{{TagTypeVideo, 0, []byte{0x27, 1}}}, []int64{0, 1}},
+	} {
+		out.Reset()
+		in := buildFLV(1, it.in...)
+		if err := Concat(out, bytes.NewReader(in), bytes.NewReader(in)); err != nil {
+			t.Fatal(err)
+		}
+		var times []int64
+		if err := NewReader(bytes.NewReader(out.Bytes())).Walk(func(tag *Tag, _ io.Reader) error {
+			times = append(times, tag.Time)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(times, it.expected) {
+			t.Errorf("got timestamps %v, expected %v", times, it.expected)
+		}
+	}
+	out.Reset()
+	if err := Concat(out, bytes.NewReader(first), bytes.NewReader(nil)); err != io.EOF {
+		t.Errorf("got: %v, expected: %v", err, io.EOF)
+	}
+}
+
+func TestRemux(t *testing.T) {
+	tags := append([]testTag{}, testTags...)
+	tags = append(tags[:4], append([]testTag{{TagTypeScript, 23, append([]byte{0x02}, amfString("onCuePoint")...)}}, tags[4:]...)...)
+	in := buildFLV(5, tags...)
+	for _, drop := range []bool{false, true} {
+		out := &bytes.Buffer{}
+		if err := Remux(bytes.NewReader(in), out, drop); err != nil {
+			t.Fatal(err)
+		}
+		r := NewReader(bytes.NewReader(out.Bytes()))
+		r.Strict = true
+		n, scripts := 0, 0
+		err := r.Walk(func(tag *Tag, _ io.Reader) error {
+			if n++; tag.Type == TagTypeScript {
+				scripts++
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !drop && (n != len(tags) || scripts != 2 || !bytes.Equal(out.Bytes(), in)) {
+			t.Errorf("got %d tags with %d scripts", n, scripts)
+		}
+		if drop && (n != len(tags)-2 || scripts != 0) {
+			t.Errorf("got %d tags with %d scripts", n, scripts)
+		}
+	}
+}
+
+func TestNormalizeTimestamps(t *testing.T) {
+	video := []byte{0x27, 1, 0, 0, 0}
+	audio := []byte{0xaf, 1, 0x21}
+	var tags []testTag
+	// Timestamps reset after the first segment, the next one starts with extended timestamps.
+	for _, segment := range []int64{0xfffff0, 0, 0} {
+		for ms := int64(0); ms < 2000; ms += 400 {
+			// Audio slightly behind video is not a reset.
+			tags = append(tags, testTag{TagTypeVideo, segment + ms + 20, video}, testTag{TagTypeAudio, segment + ms, audio})
+		}
+	}
+	out := &bytes.Buffer{}
+	if err := NormalizeTimestamps(bytes.NewReader(buildFLV(5, tags...)), out); err != nil {
+		t.Fatal(err)
+	}
+	r := NewReader(bytes.NewReader(out.Bytes()))
+	r.Strict = true
+	var times []int64
+	err := r.Walk(func(tag *Tag, _ io.Reader) error {
+		if tag.Type == TagTypeVideo {
+			times = append(times, tag.Time)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 15 {
+		t.Fatalf("got %d video tags, expected 15", len(times))
+	}
+	for i, ms := range times {
+		if expected := int64(0xfffff0 + i/5*1620 + i%5*400 + 20); ms != expected {
+			t.Errorf("tag %d: got: %d, expected: %d", i, ms, expected)
+		}
+	}
+}
+
+func TestInjectMetadata(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	m := &Metadata{Duration: 1, Width: 640, Height: 480, Encoder: "go-flv"}
+	for _, seekable := range []bool{false, true} {
+		var r io.Reader = bytes.NewReader(in)
+		if !seekable {
+			r = bytes.NewBuffer(in)
+		}
+		out := &bytes.Buffer{}
+		if err := InjectMetadata(r, out, m); err != nil {
+			t.Fatal(err)
+		}
+		fr := NewReader(bytes.NewReader(out.Bytes()))
+		fr.Strict = true
+		got, err := fr.ReadMetadataOnly()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := *m
+		if seekable {
+			expected.FileSize = float64(out.Len())
+			expected.LastTimestamp = float64(0x1234567) / 1000
+		}
+		if !reflect.DeepEqual(got, &expected) {
+			t.Errorf("got: %#v, expected: %#v", got, &expected)
+		}
+		n := 0
+		for tag := range fr.All() {
+			if tag.Type == TagTypeScript {
+				t.Errorf("got script tag %s", tag)
+			}
+			n++
+		}
+		if err = fr.Err(); err != nil || n != len(testTags)-1 {
+			t.Errorf("got %d tags: %v", n, err)
+		}
+	}
+	if m.FileSize != 0 {
+		t.Errorf("metadata is modified")
+	}
+}
+
+func TestComputeFilesize(t *testing.T) {
+	// onMetaData with a reserved bit of the tag type set is replaced as well.
+	reserved := append([]testTag{{0x40 | TagTypeScript, 0, testTags[0].data}}, testTags[1:]...)
+	for _, it := range []struct {
+		in []byte
+		m  *Metadata
+	}{
+		{buildFLV(5, testTags...), &Metadata{}},
+		{buildFLV(5, testTags...), &Metadata{Duration: 1, Encoder: "go-flv", LastTimestamp: 2}},
+		{buildFLV(5, reserved...), &Metadata{Duration: 1}},
+	} {
+		in, m := it.in, it.m
+		size, err := ComputeFilesize(bytes.NewReader(in), m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.FileSize != 0 {
+			t.Errorf("metadata is modified")
+		}
+		meta := *m
+		meta.FileSize = float64(size)
+		out := &bytes.Buffer{}
+		if err = InjectMetadata(bytes.NewBuffer(in), out, &meta); err != nil {
+			t.Fatal(err)
+		}
+		if int64(out.Len()) != size {
+			t.Errorf("got size %d, expected %d", size, out.Len())
+		}
+		got, err := NewReader(bytes.NewReader(out.Bytes())).ReadMetadataOnly()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.FileSize != float64(out.Len()) {
+			t.Errorf("got file size %v, expected %d", got.FileSize, out.Len())
+		}
+	}
+}
+
+func TestReaderSplit(t *testing.T) {
+	tags := []testTag{
+		{TagTypeScript, 0, metaDataPayload},
+		{TagTypeVideo, 0, append([]byte{0x17, 0, 0, 0, 0}, testAVCConfig...)},
+		{TagTypeAudio, 0, []byte{0xaf, 0, 0x12, 0x10}},
+	}
+	for ms := int64(0); ms < 4000; ms += 40 {
+		v := []byte{0x27, 1, 0, 0, 0, byte(ms / 40)}
+		if ms%1000 == 0 {
+			v[0] = 0x17
+		}
+		tags = append(tags, testTag{TagTypeVideo, ms, v}, testTag{TagTypeAudio, ms + 10, []byte{0xaf, 1, byte(ms / 40)}})
+	}
+	in := buildFLV(5, tags...)
+	out := &bytes.Buffer{}
+	if err := NewReader(bytes.NewReader(in)).Split(out, 1500*time.Millisecond, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	r := NewReader(bytes.NewReader(out.Bytes()))
+	r.Strict = true
+	h, err := r.ReadHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Flags() != 5 {
+		t.Errorf("got flags: %d", h.Flags())
+	}
+	m, err := r.ReadMetadataOnly()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Duration != 1 || m.Width != 1280 {
+		t.Errorf("got metadata: %#v", m)
+	}
+	for i, it := range tags[1:3] {
+		tag, b, err := r.ReadTagBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Type != it.typ || tag.Time != 0 || !bytes.Equal(b, it.data) {
+			t.Errorf("sequence header %d: got: %s %x", i, tag, b)
+		}
+	}
+	tag, b, err := r.ReadTagBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Type != TagTypeVideo || tag.Time != 0 || b[0] != 0x17 || b[5] != 50 {
+		t.Errorf("got first frame: %s %x", tag, b)
+	}
+	n := 1
+	for tag, data := range r.All() {
+		if b, _ := io.ReadAll(data); tag.Time < 0 || tag.Time >= 1000 || b[len(b)-1] < 50 {
+			t.Errorf("got frame: %s %x", tag, b)
+		}
+		n++
+	}
+	if err = r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 50 {
+		t.Errorf("got %d frames, expected %d", n, 50)
+	}
+}
+
+func TestReaderGOPs(t *testing.T) {
+	in := keyframeFLV(false, 0)
+	// Sequence headers precede the first keyframe.
+	in = append(buildFLV(5, testTags[1], testTags[2]), in[13:]...)
+	var gops [][]TagWithPayload
+	if err := NewReader(bytes.NewReader(in)).GOPs(func(gop []TagWithPayload) error {
+		gops = append(gops, gop)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(gops) != 4 {
+		t.Fatalf("got %d gops, expected %d", len(gops), 4)
+	}
+	for i, gop := range gops {
+		if len(gop) != 2+50 {
+			t.Errorf("gop %d: got %d tags, expected %d", i, len(gop), 2+50)
+		}
+		ms := int64(i * 1000)
+		if !bytes.Equal(gop[0].Data, testTags[1].data) || !bytes.Equal(gop[1].Data, testTags[2].data) || gop[0].Tag.Time != ms {
+			t.Errorf("gop %d: got sequence headers %x, %x at %d", i, gop[0].Data, gop[1].Data, gop[0].Tag.Time)
+		}
+		if gop[2].Tag.Type != TagTypeVideo || gop[2].Data[0] != 0x17 || gop[2].Tag.Time != ms {
+			t.Errorf("gop %d: got first tag %s %x", i, gop[2].Tag, gop[2].Data)
+		}
+	}
+
+	var audio []testTag
+	for ms := int64(0); ms < 10000; ms += 100 {
+		audio = append(audio, testTag{TagTypeAudio, ms, []byte{0xaf, 1, 0}})
+	}
+	n := 0
+	if err := NewReader(bytes.NewReader(buildFLV(4, audio...))).GOPs(func(gop []TagWithPayload) error {
+		if len(gop) != 20 || gop[0].Tag.Time != int64(n*2000) {
+			t.Errorf("group %d: got %d tags from %s", n, len(gop), gop[0].Tag)
+		}
+		if n++; n == 3 {
+			return ErrStopWalk
+		}
+		return nil
+	}); err != nil || n != 3 {
+		t.Errorf("got %d groups: %v", n, err)
+	}
+}
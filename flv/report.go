@@ -0,0 +1,258 @@
+package flv
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+var errNoFrameRate = errors.New("flv: not enough video frames to detect frame rate")
+
+// Stats holds counts of the tags returned by the reader.
+type Stats struct {
+	AudioTags      int
+	VideoTags      int
+	ScriptTags     int
+	KeyframeCount  int   // video tags with key frames, not counting sequence headers
+	TotalBytes     int64 // total size of tag payloads
+	FirstTimestamp int64 // timestamp of the first tag
+	LastTimestamp  int64 // timestamp of the last tag
+}
+
+// Stats returns statistics of the tags read since the reader was created or reset.
+// Tags skipped by the filter are not counted.
+func (r *Reader) Stats() Stats {
+	return r.stats
+}
+
+// add counts tag t, its payload is peeked from b without consuming.
+func (s *Stats) add(t *Tag, b *bufio.Reader) {
+	if s.AudioTags+s.VideoTags+s.ScriptTags == 0 {
+		s.FirstTimestamp = t.Time
+	}
+	s.LastTimestamp = t.Time
+	s.TotalBytes += int64(t.Size)
+	switch t.Type & 0x1f {
+	case TagTypeAudio:
+		s.AudioTags++
+	case TagTypeVideo:
+		s.VideoTags++
+		p, _ := b.Peek(min(t.Size, 2))
+		if len(p) > 0 && p[0]>>4&7 == FrameTypeKey && sequenceHeader(t, p) < 0 {
+			s.KeyframeCount++
+		}
+	case TagTypeScript:
+		s.ScriptTags++
+	}
+}
+
+// Bitrates reads the header unless it is already read and the remaining tags,
+// and returns the average video and audio bitrates in kbps.
+// The bitrate is the total payload size of the track divided by the stream duration
+// between the first and the last media timestamps. It is zero for an absent track.
+func (r *Reader) Bitrates() (video, audio int, err error) {
+	var size [2]int64
+	first, last := int64(-1), int64(0)
+	err = r.Walk(func(tag *Tag, _ io.Reader) error {
+		var i int
+		switch tag.Type & 0x1f {
+		case TagTypeVideo:
+			i = 0
+		case TagTypeAudio:
+			i = 1
+		default:
+			return nil
+		}
+		size[i] += int64(tag.Size)
+		if first < 0 || tag.Time < first {
+			first = tag.Time
+		}
+		if tag.Time > last {
+			last = tag.Time
+		}
+		return nil
+	})
+	if err != nil || last <= first {
+		return 0, 0, err
+	}
+	// Bits per millisecond equal to kbps.
+	d := last - first
+	return int(size[0] * 8 / d), int(size[1] * 8 / d), nil
+}
+
+// TimestampReport describes timestamps of the audio and video tracks.
+type TimestampReport struct {
+	Video TrackTimestamps
+	Audio TrackTimestamps
+}
+
+// TrackTimestamps describes timestamps of tags of a single track.
+// Tags are indexed in the stream order from zero, counting tags of all types.
+type TrackTimestamps struct {
+	Tags        int   // number of tags
+	MinGap      int64 // minimal difference between timestamps of consecutive tags in milliseconds
+	MaxGap      int64 // maximal difference between timestamps of consecutive tags in milliseconds
+	MaxGapIndex int   // index of the tag following the maximal gap
+	Backward    []int // indices of tags with timestamp less than the one of the previous tag
+
+	gaps bool // MinGap and MaxGap are set
+}
+
+func (t *TrackTimestamps) add(i int, time, prev int64) {
+	t.Tags++
+	if t.Tags == 1 {
+		return
+	}
+	d := time - prev
+	if d < 0 {
+		t.Backward = append(t.Backward, i)
+		return
+	}
+	if !t.gaps || d < t.MinGap {
+		t.MinGap = d
+	}
+	t.gaps = true
+	if d > t.MaxGap {
+		t.MaxGap, t.MaxGapIndex = d, i
+	}
+}
+
+// TimestampReport reads the header unless it is already read and the remaining tags,
+// and reports gaps and backward jumps of timestamps per track.
+// Backward jumps are excluded from gaps.
+func (r *Reader) TimestampReport() (*TimestampReport, error) {
+	rep := &TimestampReport{}
+	var video, audio int64
+	i := 0
+	err := r.Walk(func(tag *Tag, _ io.Reader) error {
+		switch tag.Type & 0x1f {
+		case TagTypeVideo:
+			rep.Video.add(i, tag.Time, video)
+			video = tag.Time
+		case TagTypeAudio:
+			rep.Audio.add(i, tag.Time, audio)
+			audio = tag.Time
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// InterleavingReport reads the header unless it is already read and the remaining tags,
+// and reports how far timestamps of one track run ahead of the other in the stream order.
+// A large lead requires a player to buffer the leading track. Both leads are zero for a single track.
+func (r *Reader) InterleavingReport() (maxAudioLead, maxVideoLead time.Duration, err error) {
+	video, audio := int64(-1), int64(-1)
+	var audioLead, videoLead int64
+	err = r.Walk(func(tag *Tag, _ io.Reader) error {
+		switch tag.Type & 0x1f {
+		case TagTypeVideo:
+			video = tag.Time
+		case TagTypeAudio:
+			audio = tag.Time
+		default:
+			return nil
+		}
+		if video < 0 || audio < 0 {
+			return nil
+		}
+		if d := audio - video; d > audioLead {
+			audioLead = d
+		}
+		if d := video - audio; d > videoLead {
+			videoLead = d
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return time.Duration(audioLead) * time.Millisecond, time.Duration(videoLead) * time.Millisecond, nil
+}
+
+// TagDiff describes tags differing between two streams at the same index in the stream order.
+// A or B is nil if its stream ends before the tag.
+type TagDiff struct {
+	Index int
+	A, B  *Tag
+}
+
+// DiffStreams reads two FLV streams in lockstep and reports the tags with differing headers, compared by Tag.Equal.
+// Payloads are not compared. If the streams have different tag counts, the last difference reports the first tag
+// missing from the shorter stream. It stops after limit differences, or reads the whole streams if limit is not positive.
+func DiffStreams(a, b io.Reader, limit int) ([]TagDiff, error) {
+	ra, rb := NewReader(a), NewReader(b)
+	var diffs []TagDiff
+	for i := 0; limit <= 0 || len(diffs) < limit; i++ {
+		ta, _, err := ra.ReadTag()
+		if err != nil && err != io.EOF {
+			return diffs, err
+		}
+		tb, _, err := rb.ReadTag()
+		if err != nil && err != io.EOF {
+			return diffs, err
+		}
+		if ta == nil || tb == nil {
+			if ta != tb {
+				diffs = append(diffs, TagDiff{i, ta, tb})
+			}
+			break
+		}
+		if !ta.Equal(tb) {
+			diffs = append(diffs, TagDiff{i, ta, tb})
+		}
+	}
+	return diffs, nil
+}
+
+// frameRateWindow is the number of frame intervals DetectFrameRate reads.
+const frameRateWindow = 300
+
+// DetectFrameRate reads the header unless it is already read and up to 300 following video frames,
+// and returns the frame rate of the dominant frame interval, for streams without framerate in metadata.
+// The interval is the median of the intervals between decode timestamps, averaged with the intervals
+// within a millisecond of it, which vary since timestamps are rounded to milliseconds.
+// Sequence headers, end of sequence and command frames are skipped, as well as tags with empty or malformed header.
+func (r *Reader) DetectFrameRate() (float64, error) {
+	var intervals []int64
+	last := int64(-1)
+	err := r.Walk(func(tag *Tag, data io.Reader) error {
+		if tag.Type != TagTypeVideo {
+			return nil
+		}
+		h, _, err := ParseVideoHeader(data)
+		if err != nil || !h.isFrame() {
+			return nil
+		}
+		if last >= 0 && tag.Time > last {
+			intervals = append(intervals, tag.Time-last)
+		}
+		last = tag.Time
+		if len(intervals) == frameRateWindow {
+			return ErrStopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(intervals) == 0 {
+		return 0, errNoFrameRate
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+	median := intervals[len(intervals)/2]
+	var sum, n int64
+	for _, d := range intervals {
+		if d >= median-1 && d <= median+1 {
+			sum += d
+			n++
+		}
+	}
+	return float64(n) * 1000 / float64(sum), nil
+}
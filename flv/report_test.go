@@ -0,0 +1,214 @@
+package flv
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReaderBitrates(t *testing.T) {
+	var video, audio []testTag
+	for ms := int64(0); ms <= 1000; ms += 100 {
+		video = append(video, testTag{TagTypeVideo, ms, make([]byte, 1000)})
+	}
+	for ms := int64(0); ms <= 1000; ms += 20 {
+		audio = append(audio, testTag{TagTypeAudio, ms, make([]byte, 250)})
+	}
+	for _, it := range []struct {
+		tags         []testTag
+		video, audio int
+	}{
+		{append(append([]testTag{testTags[0]}, video...), audio...), 88, 102},
+		{video, 88, 0},
+		{audio, 0, 102},
+		{testTags[:1], 0, 0},
+	} {
+		v, a, err := NewReader(bytes.NewReader(buildFLV(5, it.tags...))).Bitrates()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != it.video || a != it.audio {
+			t.Errorf("got: %d/%d kbps, expected: %d/%d kbps", v, a, it.video, it.audio)
+		}
+	}
+}
+
+func TestReaderTimestampReport(t *testing.T) {
+	tags := []testTag{
+		testTags[0],
+		{TagTypeVideo, 0, []byte{0x17}},
+		{TagTypeAudio, 0, []byte{0xaf}},
+		{TagTypeAudio, 23, []byte{0xaf}},
+		{TagTypeVideo, 40, []byte{0x27}},
+		{TagTypeAudio, 46, []byte{0xaf}},
+		{TagTypeVideo, 80, []byte{0x27}},
+		// Bad splice.
+		{TagTypeVideo, 20, []byte{0x17}},
+		{TagTypeAudio, 10, []byte{0xaf}},
+		{TagTypeVideo, 60, []byte{0x27}},
+		{TagTypeAudio, 1010, []byte{0xaf}},
+	}
+	rep, err := NewReader(bytes.NewReader(buildFLV(5, tags...))).TimestampReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &TimestampReport{
+		Video: TrackTimestamps{Tags: 5, MinGap: 40, MaxGap: 40, MaxGapIndex: 4, Backward: []int{7}, gaps: true},
+		Audio: TrackTimestamps{Tags: 5, MinGap: 23, MaxGap: 1000, MaxGapIndex: 10, Backward: []int{8}, gaps: true},
+	}
+	if !reflect.DeepEqual(rep, expected) {
+		t.Errorf("got: %+v, expected: %+v", rep, expected)
+	}
+	// The first interval goes backward.
+	tags = []testTag{{TagTypeVideo, 100, []byte{0x27}}, {TagTypeVideo, 50, []byte{0x27}}, {TagTypeVideo, 90, []byte{0x27}}, {TagTypeVideo, 130, []byte{0x27}}}
+	rep, err = NewReader(bytes.NewReader(buildFLV(1, tags...))).TimestampReport()
+	if expected := (TrackTimestamps{Tags: 4, MinGap: 40, MaxGap: 40, MaxGapIndex: 2, Backward: []int{1}, gaps: true}); err != nil || !reflect.DeepEqual(rep.Video, expected) {
+		t.Errorf("got: %+v, %v, expected: %+v", rep.Video, err, expected)
+	}
+	rep, err = NewReader(bytes.NewReader(buildFLV(5, testTags[:2]...))).TimestampReport()
+	if expected := (&TimestampReport{Video: TrackTimestamps{Tags: 1}}); err != nil || !reflect.DeepEqual(rep, expected) {
+		t.Errorf("got: %+v, %v, expected: %+v", rep, err, expected)
+	}
+}
+
+func TestReaderInterleavingReport(t *testing.T) {
+	var interleaved, video, audio []testTag
+	for ms := int64(0); ms < 2000; ms += 40 {
+		interleaved = append(interleaved, testTag{TagTypeVideo, ms, []byte{0x27}}, testTag{TagTypeAudio, ms + 20, []byte{0xaf}})
+		video = append(video, testTag{TagTypeVideo, ms, []byte{0x27}})
+		audio = append(audio, testTag{TagTypeAudio, ms + 20, []byte{0xaf}})
+	}
+	for _, it := range []struct {
+		name         string
+		tags         []testTag
+		audio, video time.Duration
+	}{
+		{"interleaved", interleaved, 20 * time.Millisecond, 20 * time.Millisecond},
+		{"video first", append(append([]testTag{}, video...), audio...), 20 * time.Millisecond, 1940 * time.Millisecond},
+		{"audio first", append(append([]testTag{}, audio...), video...), 1980 * time.Millisecond, 0},
+		{"video only", video, 0, 0},
+		{"audio only", audio, 0, 0},
+	} {
+		a, v, err := NewReader(bytes.NewReader(buildFLV(5, it.tags...))).InterleavingReport()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a != it.audio || v != it.video {
+			t.Errorf("%s: got audio lead %v, video lead %v, expected %v, %v", it.name, a, v, it.audio, it.video)
+		}
+	}
+}
+
+func TestReaderStats(t *testing.T) {
+	key := []byte{0x17, 1, 0, 0, 0, 0x65}
+	tags := append(append([]testTag{}, testTags...), testTag{TagTypeVideo, 0x1234600, key})
+	r := NewReader(bytes.NewReader(buildFLV(5, tags...)))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, it := range tags {
+		_, data, err := r.ReadTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := io.ReadAll(data); !bytes.Equal(b, it.data) {
+			t.Errorf("got payload: %x, expected: %x", b, it.data)
+		}
+		total += len(it.data)
+	}
+	expected := Stats{
+		AudioTags:      2,
+		VideoTags:      4,
+		ScriptTags:     1,
+		KeyframeCount:  1,
+		TotalBytes:     int64(total),
+		FirstTimestamp: 0,
+		LastTimestamp:  0x1234600,
+	}
+	if s := r.Stats(); s != expected {
+		t.Errorf("got: %+v, expected: %+v", s, expected)
+	}
+	r.Reset(bytes.NewReader(nil))
+	if s := r.Stats(); s != (Stats{}) {
+		t.Errorf("got after reset: %+v", s)
+	}
+}
+
+func TestDiffStreams(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	diffs, err := DiffStreams(bytes.NewReader(in), bytes.NewReader(in), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("got diffs: %v", diffs)
+	}
+	tags := append([]testTag(nil), testTags[:5]...)
+	tags[1].time = 10
+	tags[3].data = tags[3].data[1:]
+	diffs, err = DiffStreams(bytes.NewReader(in), bytes.NewReader(buildFLV(5, tags...)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, d := range diffs {
+		got = append(got, d.Index)
+	}
+	if !reflect.DeepEqual(got, []int{1, 3, 5}) {
+		t.Fatalf("got diffs at %v, expected at %v", got, []int{1, 3, 5})
+	}
+	if diffs[0].A.Time != 0 || diffs[0].B.Time != 10 {
+		t.Errorf("got times %d, %d, expected %d, %d", diffs[0].A.Time, diffs[0].B.Time, 0, 10)
+	}
+	if diffs[1].A.Size != len(testTags[3].data) || diffs[1].B.Size != len(tags[3].data) {
+		t.Errorf("got sizes %d, %d", diffs[1].A.Size, diffs[1].B.Size)
+	}
+	if diffs[2].A == nil || diffs[2].B != nil {
+		t.Errorf("got truncation diff %+v", diffs[2])
+	}
+	// The limit stops the comparison.
+	diffs, err = DiffStreams(bytes.NewReader(in), bytes.NewReader(buildFLV(5, tags...)), 2)
+	if err != nil || len(diffs) != 2 || diffs[1].Index != 3 {
+		t.Errorf("got: %v, %v, expected diffs at %v", diffs, err, []int{1, 3})
+	}
+}
+
+func TestReaderDetectFrameRate(t *testing.T) {
+	frames := func(fps float64, n int, gap func(i int) bool) []testTag {
+		tags := []testTag{{TagTypeVideo, 0, []byte{0x17, 0, 0, 0, 0, 1}}}
+		ms := 0.0
+		for i := 0; i < n; i++ {
+			tags = append(tags, testTag{TagTypeVideo, int64(ms), []byte{0x27, 1, 0, 0, 40, 9}}, testTag{TagTypeAudio, int64(ms), []byte{0xaf, 1, 0}})
+			if ms += 1000 / fps; gap(i) {
+				ms += 1000 / fps
+			}
+		}
+		return tags
+	}
+	never := func(int) bool { return false }
+	for _, it := range []struct {
+		tags []testTag
+		fps  float64
+	}{
+		{frames(30, 100, never), 30},
+		{frames(29.97, 1000, never), 29.97},
+		{frames(25, 100, func(i int) bool { return i%10 == 0 }), 25},
+		// Empty video tag is skipped.
+		{append(frames(30, 50, never), testTag{TagTypeVideo, 1667, nil}, testTag{TagTypeVideo, 1700, []byte{0x27, 1, 0, 0, 40, 9}}), 30},
+	} {
+		fps, err := NewReader(bytes.NewReader(buildFLV(5, it.tags...))).DetectFrameRate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(fps-it.fps) > 0.1 {
+			t.Errorf("got %v fps, expected %v", fps, it.fps)
+		}
+	}
+	if _, err := NewReader(bytes.NewReader(buildFLV(5, testTags[:3]...))).DetectFrameRate(); err != errNoFrameRate {
+		t.Errorf("got: %v, expected: %v", err, errNoFrameRate)
+	}
+}
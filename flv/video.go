@@ -46,6 +46,256 @@ func ParseVideoFormat(b []byte) (*VideoFormat, error) {
 	return c, nil
 }
 
+// Frame types of the video tag header.
+const (
+	FrameTypeKey             byte = 1 // key frame, a seekable frame
+	FrameTypeInter           byte = 2 // inter frame, a non-seekable frame
+	FrameTypeDisposableInter byte = 3 // disposable inter frame, H.263 only
+	FrameTypeGeneratedKey    byte = 4 // generated key frame, reserved for server use only
+	FrameTypeInfo            byte = 5 // video info/command frame
+)
+
+// Codec identifiers of the video tag header.
+const (
+	CodecIDJPEG     byte = 1
+	CodecIDH263     byte = 2
+	CodecIDScreen   byte = 3
+	CodecIDVP6      byte = 4
+	CodecIDVP6Alpha byte = 5
+	CodecIDScreen2  byte = 6
+	CodecIDAVC      byte = 7
+)
+
+// AVC packet types of the video tag header.
+const (
+	AVCPacketTypeSequenceHeader byte = 0
+	AVCPacketTypeNALU           byte = 1
+	AVCPacketTypeEndOfSequence  byte = 2
+)
+
+// Packet types of the enhanced video tag header.
+const (
+	PacketTypeSequenceStart        byte = 0
+	PacketTypeCodedFrames          byte = 1
+	PacketTypeSequenceEnd          byte = 2
+	PacketTypeCodedFramesX         byte = 3 // coded frames without composition time offset
+	PacketTypeMetadata             byte = 4
+	PacketTypeMPEG2TSSequenceStart byte = 5
+	PacketTypeMultitrack           byte = 6 // frames of several tracks
+)
+
+// Multitrack types of the enhanced audio and video multitrack packets.
+const (
+	MultitrackTypeOneTrack             byte = 0 // a single track
+	MultitrackTypeManyTracks           byte = 1 // several tracks of the same codec, prefixed with sizes
+	MultitrackTypeManyTracksManyCodecs byte = 2 // several tracks with own FourCC, prefixed with sizes
+)
+
+// FourCC codec identifiers of the enhanced video tag header.
+var (
+	FourCCAVC  = [4]byte{'a', 'v', 'c', '1'}
+	FourCCHEVC = [4]byte{'h', 'v', 'c', '1'}
+	FourCCAV1  = [4]byte{'a', 'v', '0', '1'}
+	FourCCVP9  = [4]byte{'v', 'p', '0', '9'}
+)
+
+// VideoHeader represents the header of the video tag payload.
+// Enhanced headers as defined by Enhanced RTMP specification carry FourCC and PacketType instead of CodecID.
+type VideoHeader struct {
+	FrameType       byte
+	CodecID         byte
+	AVCPacketType   byte   // only for AVC codec
+	CompositionTime int32  // composition time offset in milliseconds, only for AVC and HEVC codecs
+	Adjustment      byte   // horizontal and vertical adjustments in high and low nibbles, only for VP6 codecs
+	VP6Alpha        bool   // VP6 with alpha channel
+	AlphaOffset     uint32 // offset of the alpha data from the video data, only for VP6 with alpha
+	Enhanced        bool
+	FourCC          [4]byte // only for enhanced header
+	PacketType      byte    // only for enhanced header
+	Command         byte    // video command of enhanced command frame
+	Multitrack      bool    // only for enhanced header, PacketType is the packet type of the tracks then
+	MultitrackType  byte    // only for multitrack packet
+	TrackID         byte    // track identifier of multitrack packet, 0 otherwise
+}
+
+// IsKeyframe reports whether the tag contains a seekable frame.
+func (v *VideoHeader) IsKeyframe() bool {
+	return v.FrameType == FrameTypeKey
+}
+
+// IsEndOfSequence reports whether the tag marks the end of the video sequence.
+// Such tags usually have no video data and are sent at the end of the stream.
+func (v *VideoHeader) IsEndOfSequence() bool {
+	if v.Enhanced {
+		return v.FrameType != FrameTypeInfo && v.PacketType == PacketTypeSequenceEnd
+	}
+	return v.CodecID == CodecIDAVC && v.AVCPacketType == AVCPacketTypeEndOfSequence
+}
+
+// isFrame reports whether the tag carries a video frame rather than a sequence header,
+// the end of sequence or a command.
+func (v *VideoHeader) isFrame() bool {
+	switch {
+	case v.FrameType == FrameTypeInfo || v.IsEndOfSequence():
+		return false
+	case v.Enhanced:
+		return v.PacketType != PacketTypeSequenceStart && v.PacketType != PacketTypeMetadata
+	}
+	return v.CodecID != CodecIDAVC || v.AVCPacketType != AVCPacketTypeSequenceHeader
+}
+
+// PTS returns the presentation timestamp of the frame with decode timestamp dts, that is the tag timestamp.
+// They differ by the composition time offset, which may be negative, for streams with B-frames.
+func (v *VideoHeader) PTS(dts int64) int64 {
+	return dts + int64(v.CompositionTime)
+}
+
+// ParseVideoHeader reads the video tag header from r.
+// It returns the reader positioned at the video data, for AVC it is a sequence of NALUs,
+// for VP6 with alpha it is followed by the alpha data at AlphaOffset.
+// For multitrack packet the header and data of the first track are returned, see WalkVideoTracks.
+func ParseVideoHeader(r io.Reader) (*VideoHeader, io.Reader, error) {
+	var b [5]byte
+	if _, err := io.ReadFull(r, b[:1]); err != nil {
+		return nil, nil, err
+	}
+	if b[0]&0x80 != 0 {
+		return parseEnhancedVideoHeader(r, b[0])
+	}
+	h := &VideoHeader{
+		FrameType: b[0] >> 4,
+		CodecID:   b[0] & 0xf,
+	}
+	switch h.CodecID {
+	case CodecIDAVC:
+		if _, err := io.ReadFull(r, b[1:]); err != nil {
+			return nil, nil, unexpectedEOF(err)
+		}
+		h.AVCPacketType = b[1]
+		h.CompositionTime = getSignedInt24(b[2:])
+	case CodecIDVP6:
+		if _, err := io.ReadFull(r, b[1:2]); err != nil {
+			return nil, nil, unexpectedEOF(err)
+		}
+		h.Adjustment = b[1]
+	case CodecIDVP6Alpha:
+		if _, err := io.ReadFull(r, b[1:]); err != nil {
+			return nil, nil, unexpectedEOF(err)
+		}
+		h.Adjustment = b[1]
+		h.VP6Alpha = true
+		h.AlphaOffset = getUint24(b[2:])
+	}
+	return h, r, nil
+}
+
+func parseEnhancedVideoHeader(r io.Reader, t byte) (*VideoHeader, io.Reader, error) {
+	var b [4]byte
+	h := &VideoHeader{
+		FrameType:  t >> 4 & 7,
+		Enhanced:   true,
+		PacketType: t & 0xf,
+	}
+	if h.FrameType == FrameTypeInfo && h.PacketType != PacketTypeMetadata {
+		if _, err := io.ReadFull(r, b[:1]); err != nil {
+			return nil, nil, unexpectedEOF(err)
+		}
+		h.Command = b[0]
+		return h, r, nil
+	}
+	if h.PacketType == PacketTypeMultitrack {
+		if _, err := io.ReadFull(r, b[:1]); err != nil {
+			return nil, nil, unexpectedEOF(err)
+		}
+		h.Multitrack = true
+		h.MultitrackType = b[0] >> 4
+		h.PacketType = b[0] & 0xf
+		if h.MultitrackType != MultitrackTypeManyTracksManyCodecs {
+			if _, err := io.ReadFull(r, h.FourCC[:]); err != nil {
+				return nil, nil, unexpectedEOF(err)
+			}
+		}
+		h, data, err := parseVideoTrack(r, h)
+		return h, data, unexpectedEOF(err)
+	}
+	if _, err := io.ReadFull(r, h.FourCC[:]); err != nil {
+		return nil, nil, unexpectedEOF(err)
+	}
+	if err := readCompositionTime(r, h); err != nil {
+		return nil, nil, err
+	}
+	return h, r, nil
+}
+
+// parseVideoTrack reads the track header of multitrack packet, it returns io.EOF if there are no more tracks.
+// The returned reader is limited to the track data unless the packet has one track.
+func parseVideoTrack(r io.Reader, h *VideoHeader) (*VideoHeader, io.Reader, error) {
+	var b [3]byte
+	if h.MultitrackType == MultitrackTypeManyTracksManyCodecs {
+		if _, err := io.ReadFull(r, h.FourCC[:]); err != nil {
+			return nil, nil, err
+		}
+		if _, err := io.ReadFull(r, b[:1]); err != nil {
+			return nil, nil, unexpectedEOF(err)
+		}
+	} else if _, err := io.ReadFull(r, b[:1]); err != nil {
+		return nil, nil, err
+	}
+	h.TrackID = b[0]
+	if h.MultitrackType != MultitrackTypeOneTrack {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, nil, unexpectedEOF(err)
+		}
+		r = io.LimitReader(r, int64(getUint24(b[:])))
+	}
+	if err := readCompositionTime(r, h); err != nil {
+		return nil, nil, err
+	}
+	return h, r, nil
+}
+
+// readCompositionTime reads the composition time offset of the enhanced coded frames if they have it.
+func readCompositionTime(r io.Reader, h *VideoHeader) error {
+	if h.PacketType == PacketTypeCodedFrames && (h.FourCC == FourCCAVC || h.FourCC == FourCCHEVC) {
+		var b [3]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+		h.CompositionTime = getSignedInt24(b[:])
+	}
+	return nil
+}
+
+// WalkVideoTracks reads the video tag payload from r and calls fn for each track of multitrack packet,
+// or once for other packets. The rest of track data not consumed by fn is skipped.
+// WalkVideoTracks stops when fn returns an error.
+func WalkVideoTracks(r io.Reader, fn func(h *VideoHeader, data io.Reader) error) error {
+	h, data, err := ParseVideoHeader(r)
+	if err != nil {
+		return err
+	}
+	for {
+		if err = fn(h, data); err != nil {
+			return err
+		}
+		if !h.Multitrack || h.MultitrackType == MultitrackTypeOneTrack {
+			return nil
+		}
+		if _, err = io.Copy(io.Discard, data); err != nil {
+			return err
+		}
+		next := *h
+		next.TrackID, next.CompositionTime = 0, 0
+		h, data, err = parseVideoTrack(r, &next)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
 type VideoFrame struct {
 	format  *VideoFormat
 	time    time.Duration
@@ -1,6 +1,10 @@
 package flv
 
-import "testing"
+import (
+	"bytes"
+	"io"
+	"testing"
+)
 
 func TestVideoFormat(t *testing.T) {
 	for _, it := range []struct {
@@ -22,3 +26,160 @@ func TestVideoFormat(t *testing.T) {
 		}
 	}
 }
+
+func TestVideoHeader(t *testing.T) {
+	for _, it := range []struct {
+		b      []byte
+		header VideoHeader
+		data   []byte
+	}{
+		{[]byte{0x17, 0x00, 0x00, 0x00, 0x00, 0x01, 0x64}, VideoHeader{FrameType: FrameTypeKey, CodecID: CodecIDAVC, AVCPacketType: AVCPacketTypeSequenceHeader}, []byte{0x01, 0x64}},
+		{[]byte{0x27, 0x01, 0x00, 0x00, 0x42, 0x00, 0x00, 0x00, 0x02, 0x09, 0xf0}, VideoHeader{FrameType: FrameTypeInter, CodecID: CodecIDAVC, AVCPacketType: AVCPacketTypeNALU, CompositionTime: 0x42}, []byte{0x00, 0x00, 0x00, 0x02, 0x09, 0xf0}},
+		{[]byte{0x12, 0x00, 0x08}, VideoHeader{FrameType: FrameTypeKey, CodecID: CodecIDH263}, []byte{0x00, 0x08}},
+		{[]byte{0x14, 0x21, 0x00, 0x08}, VideoHeader{FrameType: FrameTypeKey, CodecID: CodecIDVP6, Adjustment: 0x21}, []byte{0x00, 0x08}},
+		{[]byte{0x25, 0x10, 0x00, 0x00, 0x02, 0x00, 0x08, 0x01}, VideoHeader{FrameType: FrameTypeInter, CodecID: CodecIDVP6Alpha, Adjustment: 0x10, VP6Alpha: true, AlphaOffset: 2}, []byte{0x00, 0x08, 0x01}},
+	} {
+		h, r, err := ParseVideoHeader(bytes.NewReader(it.b))
+		if err != nil {
+			t.Fatalf("%v: %x", err, it.b)
+		}
+		if *h != it.header {
+			t.Errorf("got: %#v, expected: %#v", h, it.header)
+		}
+		if h.IsKeyframe() != (it.header.FrameType == FrameTypeKey) {
+			t.Errorf("IsKeyframe mismatch: %#v", h)
+		}
+		data, _ := io.ReadAll(r)
+		if !bytes.Equal(data, it.data) {
+			t.Errorf("got data: %x, expected: %x", data, it.data)
+		}
+	}
+	for _, b := range [][]byte{{0x17, 0x01}, {0x14}, {0x15, 0x00, 0x00}} {
+		if _, _, err := ParseVideoHeader(bytes.NewReader(b)); err != io.ErrUnexpectedEOF {
+			t.Errorf("got: %v, expected: %v for %x", err, io.ErrUnexpectedEOF, b)
+		}
+	}
+}
+
+func TestEnhancedVideoHeader(t *testing.T) {
+	for _, it := range []struct {
+		b      []byte
+		header VideoHeader
+		data   []byte
+	}{
+		{[]byte{0x90, 'h', 'v', 'c', '1', 0x01, 0x02}, VideoHeader{FrameType: FrameTypeKey, Enhanced: true, FourCC: FourCCHEVC, PacketType: PacketTypeSequenceStart}, []byte{0x01, 0x02}},
+		{[]byte{0x91, 'h', 'v', 'c', '1', 0xff, 0xff, 0xfe, 0x26, 0x01}, VideoHeader{FrameType: FrameTypeKey, Enhanced: true, FourCC: FourCCHEVC, PacketType: PacketTypeCodedFrames, CompositionTime: -2}, []byte{0x26, 0x01}},
+		{[]byte{0xa3, 'h', 'v', 'c', '1', 0x02, 0x01}, VideoHeader{FrameType: FrameTypeInter, Enhanced: true, FourCC: FourCCHEVC, PacketType: PacketTypeCodedFramesX}, []byte{0x02, 0x01}},
+		{[]byte{0x91, 'a', 'v', '0', '1', 0x12, 0x00}, VideoHeader{FrameType: FrameTypeKey, Enhanced: true, FourCC: FourCCAV1, PacketType: PacketTypeCodedFrames}, []byte{0x12, 0x00}},
+		{[]byte{0xa1, 'v', 'p', '0', '9', 0x86}, VideoHeader{FrameType: FrameTypeInter, Enhanced: true, FourCC: FourCCVP9, PacketType: PacketTypeCodedFrames}, []byte{0x86}},
+		{[]byte{0x92, 'v', 'p', '0', '9'}, VideoHeader{FrameType: FrameTypeKey, Enhanced: true, FourCC: FourCCVP9, PacketType: PacketTypeSequenceEnd}, nil},
+		{[]byte{0xd1, 0x01}, VideoHeader{FrameType: FrameTypeInfo, Enhanced: true, PacketType: PacketTypeCodedFrames, Command: 1}, nil},
+	} {
+		h, r, err := ParseVideoHeader(bytes.NewReader(it.b))
+		if err != nil {
+			t.Fatalf("%v: %x", err, it.b)
+		}
+		if *h != it.header {
+			t.Errorf("got: %#v, expected: %#v", h, it.header)
+		}
+		data, _ := io.ReadAll(r)
+		if !bytes.Equal(data, it.data) {
+			t.Errorf("got data: %x, expected: %x", data, it.data)
+		}
+	}
+}
+
+func TestVideoEndOfSequence(t *testing.T) {
+	for _, it := range []struct {
+		b   []byte
+		end bool
+	}{
+		{[]byte{0x17, 0x02, 0, 0, 0}, true},
+		{[]byte{0x17, 0x00, 0, 0, 0, 0x01}, false},
+		{[]byte{0x27, 0x01, 0, 0, 0, 0x01}, false},
+		{[]byte{0x22, 0x02}, false},
+		{[]byte{0x92, 'v', 'p', '0', '9'}, true},
+		{[]byte{0x91, 'v', 'p', '0', '9', 0x86}, false},
+		{[]byte{0xd2, 0x01}, false},
+	} {
+		h, _, err := ParseVideoHeader(bytes.NewReader(it.b))
+		if err != nil {
+			t.Fatalf("%v: %x", err, it.b)
+		}
+		if h.IsEndOfSequence() != it.end {
+			t.Errorf("%x: got: %v, expected: %v", it.b, !it.end, it.end)
+		}
+	}
+}
+
+func TestVideoHeaderPTS(t *testing.T) {
+	for _, it := range []struct {
+		b   []byte
+		dts int64
+		pts int64
+	}{
+		{[]byte{0x27, 0x01, 0x00, 0x00, 0x50}, 1000, 1080},
+		{[]byte{0x27, 0x01, 0xff, 0xff, 0xd8}, 1000, 960},
+		{[]byte{0x27, 0x01, 0x80, 0x00, 0x00}, 0x1000000, 0x800000},
+		{[]byte{0x91, 'h', 'v', 'c', '1', 0xff, 0xff, 0xfe}, 40, 38},
+		{[]byte{0x22, 0x00}, 40, 40},
+	} {
+		h, _, err := ParseVideoHeader(bytes.NewReader(it.b))
+		if err != nil {
+			t.Fatalf("%v: %x", err, it.b)
+		}
+		if pts := h.PTS(it.dts); pts != it.pts {
+			t.Errorf("%x: got: %d, expected: %d", it.b, pts, it.pts)
+		}
+	}
+}
+
+func TestVideoMultitrack(t *testing.T) {
+	type track struct {
+		header VideoHeader
+		data   []byte
+	}
+	for _, it := range []struct {
+		b      []byte
+		tracks []track
+	}{
+		{[]byte{0xa6, 0x01, 'h', 'v', 'c', '1', 0x02, 0x00, 0x00, 0x10, 0x26, 0x01}, []track{
+			{VideoHeader{FrameType: FrameTypeInter, Enhanced: true, FourCC: FourCCHEVC, PacketType: PacketTypeCodedFrames, CompositionTime: 0x10, Multitrack: true, TrackID: 2}, []byte{0x26, 0x01}},
+		}},
+		{[]byte{0x96, 0x13, 'a', 'v', '0', '1', 0x00, 0x00, 0x00, 0x02, 0x12, 0x00, 0x01, 0x00, 0x00, 0x01, 0x0a}, []track{
+			{VideoHeader{FrameType: FrameTypeKey, Enhanced: true, FourCC: FourCCAV1, PacketType: PacketTypeCodedFramesX, Multitrack: true, MultitrackType: MultitrackTypeManyTracks}, []byte{0x12, 0x00}},
+			{VideoHeader{FrameType: FrameTypeKey, Enhanced: true, FourCC: FourCCAV1, PacketType: PacketTypeCodedFramesX, Multitrack: true, MultitrackType: MultitrackTypeManyTracks, TrackID: 1}, []byte{0x0a}},
+		}},
+		{[]byte{0x96, 0x21, 'a', 'v', 'c', '1', 0x00, 0x00, 0x00, 0x05, 0xff, 0xff, 0xfe, 0x65, 0x88, 'v', 'p', '0', '9', 0x01, 0x00, 0x00, 0x01, 0x86}, []track{
+			{VideoHeader{FrameType: FrameTypeKey, Enhanced: true, FourCC: FourCCAVC, PacketType: PacketTypeCodedFrames, CompositionTime: -2, Multitrack: true, MultitrackType: MultitrackTypeManyTracksManyCodecs}, []byte{0x65, 0x88}},
+			{VideoHeader{FrameType: FrameTypeKey, Enhanced: true, FourCC: FourCCVP9, PacketType: PacketTypeCodedFrames, Multitrack: true, MultitrackType: MultitrackTypeManyTracksManyCodecs, TrackID: 1}, []byte{0x86}},
+		}},
+		{[]byte{0x27, 0x01, 0x00, 0x00, 0x00, 0x41}, []track{
+			{VideoHeader{FrameType: FrameTypeInter, CodecID: CodecIDAVC, AVCPacketType: AVCPacketTypeNALU}, []byte{0x41}},
+		}},
+	} {
+		var got []track
+		err := WalkVideoTracks(bytes.NewReader(it.b), func(h *VideoHeader, data io.Reader) error {
+			b, err := io.ReadAll(data)
+			got = append(got, track{*h, b})
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%v: %x", err, it.b)
+		}
+		if len(got) != len(it.tracks) {
+			t.Fatalf("%x: got %d tracks, expected %d", it.b, len(got), len(it.tracks))
+		}
+		for i, tr := range it.tracks {
+			if got[i].header != tr.header {
+				t.Errorf("track %d: got: %#v, expected: %#v", i, got[i].header, tr.header)
+			}
+			if !bytes.Equal(got[i].data, tr.data) {
+				t.Errorf("track %d: got data: %x, expected: %x", i, got[i].data, tr.data)
+			}
+		}
+	}
+	if _, _, err := ParseVideoHeader(bytes.NewReader([]byte{0x96, 0x11, 'a', 'v', '0', '1', 0x00, 0x00})); err != io.ErrUnexpectedEOF {
+		t.Errorf("got: %v, expected: %v", err, io.ErrUnexpectedEOF)
+	}
+}
@@ -1,43 +1,255 @@
 package flv
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
 )
 
+var errInvalidLastTag = errors.New("flv: invalid last tag")
+
 // Writer writes FLV header and tags to an output stream.
 type Writer struct {
 	*fileWriter
+
+	// ChecksumWriter, if set, receives CRC-32 checksum entries of the written tags,
+	// a sidecar which is verified by Reader.VerifyChecksums. The FLV stream itself is not changed.
+	ChecksumWriter io.Writer
+
+	time      int64  // timestamp of the last tag
+	offset    int64  // added to timestamps of written tags
+	tags      int    // number of tags written
+	flushEach bool   // flush the output after every tag
+	header    bool   // the header is written
+	in        []byte // pending bytes of the stream relayed by Write
+	inData    bool   // the header of the stream relayed by Write is consumed
 }
 
 // NewWriter returns a new writer that writes to w.
 func NewWriter(w io.Writer) *Writer {
-	return &Writer{newFileWriter(w)}
+	return &Writer{fileWriter: newFileWriter(w)}
+}
+
+// NewBufferedWriter returns a new writer that writes to the buffered output w, like bufio.Writer.
+// If flushEach is true, the output is flushed after the header and every tag, for low latency of live streams.
+func NewBufferedWriter(w io.Writer, flushEach bool) *Writer {
+	fw := NewWriter(w)
+	fw.flushEach = flushEach
+	return fw
+}
+
+// NewAppendWriter returns a new writer that appends tags to the existing FLV stream ws.
+// It validates the header and recovers the timestamp of the last tag.
+// If ws is empty, the header with audio and video flags is written.
+func NewAppendWriter(ws io.ReadWriteSeeker) (*Writer, error) {
+	w := NewWriter(ws)
+	end, err := ws.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if end == 0 {
+		if err = w.WriteHeader(NewHeader(true, true)); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+	w.header = true
+	if _, err = ws.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	r := NewReader(ws)
+	if _, err = r.ReadHeader(); err != nil {
+		return nil, err
+	}
+	b := make([]byte, 11)
+	if _, err = ws.Seek(end-4, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err = io.ReadFull(ws, b[:4]); err != nil {
+		return nil, err
+	}
+	if size := int64(getUint32(b)); size > 0 {
+		if size < 11 || end-4-size < r.data+4 {
+			return nil, errInvalidLastTag
+		}
+		if _, err = ws.Seek(end-4-size, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err = io.ReadFull(ws, b); err != nil {
+			return nil, err
+		}
+		if int64(getInt24(b[1:]))+11 != size {
+			return nil, errInvalidLastTag
+		}
+		w.time = getTime(b[4:])
+	}
+	if _, err = ws.Seek(end, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// LastTime returns the timestamp of the last written tag in milliseconds, including the time offset.
+func (w *Writer) LastTime() int64 {
+	return w.time
+}
+
+// SetTimeOffset sets the offset in milliseconds added to timestamps of the following tags.
+// The tags passed to WriteTag are not modified.
+func (w *Writer) SetTimeOffset(ms int64) {
+	w.offset = ms
+}
+
+// Flush flushes the output if it implements Flush, like bufio.Writer, otherwise it does nothing.
+// The writer itself does not buffer data after WriteHeader and WriteTag return.
+func (w *Writer) Flush() error {
+	switch f := w.w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Flush() }:
+		f.Flush()
+	}
+	return nil
 }
 
 // WriteHeader writes FLV header.
 func (w *Writer) WriteHeader(h *Header) error {
-	b := w.next(13)
-	putUint24(b, signature)
-	b[3] = 1
-	b[4] = h.Flags
-	putUint32(b[5:], 9)
-	putUint32(b[9:], 0)
-	return w.flush()
+	w.buf = h.append(w.buf)
+	putUint32(w.next(4), 0)
+	w.header = true
+	return w.done()
+}
+
+// ReadFrom relays the FLV stream read from r until EOF, implementing io.ReaderFrom.
+// The header of r is written unless a header is already written, the tags are written by WriteTag,
+// so PreviousTagSize fields are written anew and the time offset is added to timestamps.
+// To mix relayed tags with tags written by WriteTag, the timestamps of both should be continuous,
+// since neither the timestamps of r nor their order are changed. It returns the number of bytes of r consumed.
+func (w *Writer) ReadFrom(r io.Reader) (int64, error) {
+	fr := NewReader(r)
+	h, err := fr.ReadHeader()
+	if err != nil {
+		return fr.Offset(), err
+	}
+	if !w.header {
+		if err = w.WriteHeader(h); err != nil {
+			return fr.Offset(), err
+		}
+	}
+	for {
+		tag, data, err := fr.ReadTag()
+		if err == io.EOF {
+			// The final PreviousTagSize is read but not consumed.
+			return fr.Offset() + int64(fr.b.Buffered()), nil
+		}
+		if err == nil {
+			err = w.WriteTag(tag, data)
+		}
+		if err != nil {
+			return fr.Offset(), err
+		}
+	}
 }
 
+// Write relays the FLV stream written in p in chunks of any size, implementing io.Writer, so that the stream
+// can be relayed by io.Copy whether or not it uses ReadFrom. Tags are written as by ReadFrom once they are complete,
+// the rest is kept until the next Write. A header following the final PreviousTagSize starts a new stream,
+// whose header is dropped as by ReadFrom. Write and ReadFrom should not be mixed within a single relayed stream.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.in = append(w.in, p...)
+	b := w.in
+	var err error
+	for err == nil {
+		var n int
+		if n, err = w.writeRaw(b); n == 0 {
+			break
+		}
+		b = b[n:]
+	}
+	w.in = w.in[:copy(w.in, b)]
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeRaw writes the header or the tag at the start of b and returns the number of bytes consumed,
+// which is zero if b does not hold them whole.
+func (w *Writer) writeRaw(b []byte) (int, error) {
+	if w.inData && len(b) >= 7 && getUint24(b[4:]) == signature {
+		// Skip the final PreviousTagSize of the stream and read a header of the next one.
+		w.inData = false
+		return 4, nil
+	}
+	if !w.inData {
+		if len(b) < 9 {
+			return 0, nil
+		}
+		n := int(max(9, min(getUint32(b[5:]), 1<<24)))
+		if len(b) < n {
+			return 0, nil
+		}
+		h, err := NewReader(bytes.NewReader(b[:n])).ReadHeader()
+		if err != nil {
+			return 0, err
+		}
+		if !w.header {
+			if err = w.WriteHeader(h); err != nil {
+				return 0, err
+			}
+		}
+		w.inData = true
+		return n, nil
+	}
+	if len(b) < 15 {
+		return 0, nil
+	}
+	tag := parseTag(b)
+	n := 15 + tag.Size
+	if len(b) < n {
+		return 0, nil
+	}
+	return n, w.WriteTag(tag, bytes.NewReader(b[15:n]))
+}
+
+// WriteTag writes FLV tag header, payload read from r and the trailing PreviousTagSize.
+// If tag.Size is positive, exactly tag.Size bytes are copied from r, otherwise r is read until EOF.
 func (w *Writer) WriteTag(tag *Tag, r io.Reader) error {
+	if tag.Size > 0 {
+		r = io.LimitReader(r, int64(tag.Size))
+	}
 	p := len(w.buf)
 	b := w.next(11)
-	b[0] = tag.Type
-	putTime(b[4:], tag.Time)
-	putUint24(b[8:], tag.Stream)
+	tag.putHeader(b)
+	putTime(b[4:], tag.Time+w.offset)
 	n, err := w.fill(r)
+	if err == nil && n < tag.Size {
+		err = fmt.Errorf("%w: payload %d of %d bytes: %w", ErrShortTag, n, tag.Size, io.ErrUnexpectedEOF)
+	}
 	if err != nil {
+		w.buf = w.buf[:p]
 		return err
 	}
 	putUint24(w.buf[p+1:], uint32(n))
+	if w.ChecksumWriter != nil {
+		if err = writeChecksum(w.ChecksumWriter, w.tags, w.buf[p:]); err != nil {
+			w.buf = w.buf[:p]
+			return err
+		}
+	}
 	putUint32(w.next(4), uint32(n+11))
-	return w.flush()
+	w.time = tag.Time + w.offset
+	w.tags++
+	return w.done()
+}
+
+// done writes the buffered header or tag and flushes the output if flushEach is set.
+func (w *Writer) done() error {
+	if err := w.flush(); err != nil || !w.flushEach {
+		return err
+	}
+	return w.Flush()
 }
 
 var bufferSize = 4096
@@ -0,0 +1,298 @@
+package flv
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+type testTag struct {
+	typ  uint8
+	time int64
+	data []byte
+}
+
+// buildFLV encodes a FLV stream by hand, independently of Writer.
+func buildFLV(flags uint8, tags ...testTag) []byte {
+	b := []byte{'F', 'L', 'V', 1, flags, 0, 0, 0, 9, 0, 0, 0, 0}
+	for _, it := range tags {
+		h := make([]byte, 11)
+		h[0] = it.typ
+		putUint24(h[1:], uint32(len(it.data)))
+		putTime(h[4:], it.time)
+		b = append(b, h...)
+		b = append(b, it.data...)
+		s := make([]byte, 4)
+		putUint32(s, uint32(len(it.data)+11))
+		b = append(b, s...)
+	}
+	return b
+}
+
+var testTags = []testTag{
+	{TagTypeScript, 0, []byte{2, 0, 10, 'o', 'n', 'M', 'e', 't', 'a', 'D', 'a', 't', 'a', 5}},
+	{TagTypeVideo, 0, []byte{0x17, 0, 0, 0, 0, 1, 2, 3}},
+	{TagTypeAudio, 0, []byte{0xaf, 0, 0x12, 0x10}},
+	{TagTypeAudio, 23, []byte{0xaf, 1, 1, 2, 3, 4, 5}},
+	{TagTypeVideo, 33, []byte{0x27, 1, 0, 0, 0, 9, 8, 7, 6}},
+	{TagTypeVideo, 0x1234567, []byte{0x27, 1, 0, 0, 0}},
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	r := NewReader(bytes.NewReader(in))
+	out := &bytes.Buffer{}
+	w := NewWriter(out)
+	h, err := r.ReadHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteHeader(h); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		tag, data, err := r.ReadTag()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = w.WriteTag(tag, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(in, out.Bytes()) {
+		t.Errorf("got: %x, expected: %x", out.Bytes(), in)
+	}
+}
+
+func TestWriterShortPayload(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := NewWriter(out)
+	err := w.WriteTag(&Tag{Type: TagTypeAudio, Size: 10}, bytes.NewReader([]byte{1, 2, 3}))
+	if !errors.Is(err, io.ErrUnexpectedEOF) || !errors.Is(err, ErrShortTag) {
+		t.Fatalf("got: %v, expected: %v", err, io.ErrUnexpectedEOF)
+	}
+	if err = w.WriteTag(&Tag{Type: TagTypeAudio}, bytes.NewReader([]byte{1, 2, 3})); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 11+3+4 {
+		t.Errorf("got %d bytes, expected %d", out.Len(), 11+3+4)
+	}
+}
+
+type memFile struct {
+	b   []byte
+	pos int64
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if f.pos >= int64(len(f.b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.b[f.pos:])
+	f.pos += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if d := f.pos + int64(len(p)) - int64(len(f.b)); d > 0 {
+		f.b = append(f.b, make([]byte, d)...)
+	}
+	n := copy(f.b[f.pos:], p)
+	f.pos += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Seek(off int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		off += f.pos
+	case io.SeekEnd:
+		off += int64(len(f.b))
+	}
+	if off < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.pos = off
+	return off, nil
+}
+
+func TestAppendWriter(t *testing.T) {
+	f := &memFile{}
+	w, err := NewAppendWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range testTags[:3] {
+		if err = w.WriteTag(&Tag{Type: it.typ, Time: it.time}, bytes.NewReader(it.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, it := range testTags[3:] {
+		if w, err = NewAppendWriter(f); err != nil {
+			t.Fatal(err)
+		}
+		if w.LastTime() > it.time {
+			t.Errorf("got last time %d, expected at most %d", w.LastTime(), it.time)
+		}
+		if err = w.WriteTag(&Tag{Type: it.typ, Time: it.time}, bytes.NewReader(it.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w, err = NewAppendWriter(f); err != nil {
+		t.Fatal(err)
+	}
+	if w.LastTime() != testTags[5].time {
+		t.Errorf("got last time %d, expected %d", w.LastTime(), testTags[5].time)
+	}
+	if in := buildFLV(5, testTags...); !bytes.Equal(f.b, in) {
+		t.Errorf("got: %x, expected: %x", f.b, in)
+	}
+	if _, err = NewAppendWriter(&memFile{b: []byte("FLX\x01\x05\x00\x00\x00\x09\x00\x00\x00\x00")}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("got: %v, expected: %v", err, ErrBadSignature)
+	}
+}
+
+func TestWriterTimeOffset(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := NewWriter(out)
+	if err := w.WriteHeader(NewHeader(false, true)); err != nil {
+		t.Fatal(err)
+	}
+	segment := []int64{0, 40, 80}
+	offsets := []int64{0, 120, 0xffffff - 40}
+	for _, off := range offsets {
+		w.SetTimeOffset(off)
+		for _, ms := range segment {
+			tag := &Tag{Type: TagTypeVideo, Time: ms}
+			if err := w.WriteTag(tag, bytes.NewReader([]byte{0x27, 1, 0, 0, 0})); err != nil {
+				t.Fatal(err)
+			}
+			if tag.Time != ms {
+				t.Fatalf("tag is modified: %d", tag.Time)
+			}
+		}
+	}
+	r := NewReader(bytes.NewReader(out.Bytes()))
+	if _, err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for _, off := range offsets {
+		for _, ms := range segment {
+			tag, _, err := r.ReadTag()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tag.Time != ms+off {
+				t.Errorf("got: %d, expected: %d", tag.Time, ms+off)
+			}
+		}
+	}
+	if w.LastTime() != 0xffffff+40 {
+		t.Errorf("got last time %d, expected %d", w.LastTime(), 0xffffff+40)
+	}
+}
+
+func TestBufferedWriter(t *testing.T) {
+	for _, flushEach := range []bool{false, true} {
+		out := &bytes.Buffer{}
+		b := bufio.NewWriter(out)
+		w := NewBufferedWriter(b, flushEach)
+		if err := w.WriteHeader(NewHeader(true, true)); err != nil {
+			t.Fatal(err)
+		}
+		n := 13
+		for _, it := range testTags {
+			if err := w.WriteTag(&Tag{Type: it.typ, Time: it.time}, bytes.NewReader(it.data)); err != nil {
+				t.Fatal(err)
+			}
+			n += 11 + len(it.data) + 4
+			if flushEach && out.Len() != n {
+				t.Errorf("got %d bytes, expected %d", out.Len(), n)
+			}
+		}
+		if !flushEach && out.Len() != 0 {
+			t.Errorf("got %d bytes before Flush", out.Len())
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if in := buildFLV(5, testTags...); !bytes.Equal(out.Bytes(), in) {
+			t.Errorf("got: %x, expected: %x", out.Bytes(), in)
+		}
+	}
+	if err := NewWriter(&bytes.Buffer{}).Flush(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriterReadFrom(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	out := &bytes.Buffer{}
+	w := NewWriter(out)
+	// The source hides bytes.Reader.WriteTo, so io.Copy calls ReadFrom.
+	n, err := io.Copy(w, struct{ io.Reader }{bytes.NewReader(in)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(in)) {
+		t.Errorf("got %d bytes read, expected %d", n, len(in))
+	}
+	if !bytes.Equal(out.Bytes(), in) {
+		t.Errorf("got: %x, expected: %x", out.Bytes(), in)
+	}
+
+	// The header of the second stream is dropped and its tags follow the written ones.
+	tag := testTags[5]
+	if err = w.WriteTag(&Tag{Type: tag.typ, Time: tag.time}, bytes.NewReader(tag.data)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.Copy(w, struct{ io.Reader }{bytes.NewReader(buildFLV(5, testTags[:2]...))}); err != nil {
+		t.Fatal(err)
+	}
+	expected := buildFLV(5, append(append(testTags[:len(testTags):len(testTags)], tag), testTags[:2]...)...)
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("got: %x, expected: %x", out.Bytes(), expected)
+	}
+	if _, err = w.ReadFrom(bytes.NewReader(in[:20])); !errors.Is(err, ErrShortTag) {
+		t.Errorf("got: %v, expected: %v", err, ErrShortTag)
+	}
+}
+
+func TestWriterWrite(t *testing.T) {
+	in := buildFLV(5, testTags...)
+	for _, chunk := range []int{len(in), 7, 1} {
+		out := &bytes.Buffer{}
+		w := NewWriter(out)
+		// io.Copy calls Write through bytes.Reader.WriteTo.
+		if _, err := io.Copy(w, bytes.NewReader(in)); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), in) {
+			t.Errorf("got: %x, expected: %x", out.Bytes(), in)
+		}
+		// The header of the second stream is dropped and its tags follow the relayed ones.
+		second := buildFLV(5, testTags[:2]...)
+		for b := second; len(b) > 0; {
+			n := min(len(b), chunk)
+			if _, err := w.Write(b[:n]); err != nil {
+				t.Fatal(err)
+			}
+			b = b[n:]
+		}
+		expected := buildFLV(5, append(testTags[:len(testTags):len(testTags)], testTags[:2]...)...)
+		if !bytes.Equal(out.Bytes(), expected) {
+			t.Errorf("chunk %d: got: %x, expected: %x", chunk, out.Bytes(), expected)
+		}
+		if w.LastTime() != testTags[1].time {
+			t.Errorf("chunk %d: got last time %d, expected %d", chunk, w.LastTime(), testTags[1].time)
+		}
+	}
+	if _, err := NewWriter(io.Discard).Write([]byte("FLV\x02\x05\x00\x00\x00\x09")); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("got: %v, expected: %v", err, ErrUnsupportedVersion)
+	}
+}